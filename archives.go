@@ -0,0 +1,442 @@
+/**
+ * Spectrum-Bootstrap - A bootstrap for Minecraft launchers
+ * Copyright (C) 2023-2024 - Oxodao
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ **/
+
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// The archives are kept here once downloaded, named after their hash
+// Next to each one, a json file lists what was extracted from it
+const ARCHIVES_CACHE_DIR = "archives"
+
+// Archives can't extract more than this, a small zip bomb would otherwise fill the disk
+const ARCHIVE_MAX_EXTRACTED_SIZE = 8 << 30
+
+const (
+	ArchiveFormatZip    = "zip"
+	ArchiveFormatTarGz  = "tar.gz"
+	ArchiveFormatTarZst = "tar.zst"
+)
+
+var ArchiveFormats = []string{ArchiveFormatZip, ArchiveFormatTarGz, ArchiveFormatTarZst}
+
+var (
+	ErrUnknownArchiveFormat = errors.New("unknown archive format")
+	ErrUnsafeArchiveEntry   = errors.New("unsafe archive entry")
+	ErrArchiveTooLarge      = errors.New("archive too large")
+)
+
+// The format when given, otherwise guessed from the url
+func (f ManifestFile) GetArchiveFormat() string {
+	return GetArchiveFormat(f.Format, f.Url)
+}
+
+func GetArchiveFormat(format, url string) string {
+	if len(format) > 0 {
+		return format
+	}
+
+	u := strings.ToLower(url)
+	if i := strings.IndexAny(u, "?#"); i >= 0 {
+		u = u[:i]
+	}
+
+	switch {
+	case strings.HasSuffix(u, ".zip"):
+		return ArchiveFormatZip
+	case strings.HasSuffix(u, ".tar.gz"), strings.HasSuffix(u, ".tgz"):
+		return ArchiveFormatTarGz
+	case strings.HasSuffix(u, ".tar.zst"), strings.HasSuffix(u, ".tzst"):
+		return ArchiveFormatTarZst
+	}
+
+	return ""
+}
+
+func (m *LauncherManager) getArchivePath(f ManifestFile) string {
+	return filepath.Join(m.bSettings.LauncherPath, ".cache", ARCHIVES_CACHE_DIR, strings.ToLower(f.Hash))
+}
+
+func (m *LauncherManager) getArchiveIndexPath(f ManifestFile) string {
+	return m.getArchivePath(f) + ".json"
+}
+
+// Checks the files extracted from the archive, they are all returned so that they are not pruned
+// even when some of them were modified
+func CheckExtractedArchive(indexPath, target string) ([]string, bool) {
+	index, err := LoadFromCache[ArchiveIndex](indexPath)
+	if err != nil || index == nil {
+		return nil, false
+	}
+
+	files := []string{}
+	valid := true
+
+	for rel, hash := range index.Files {
+		file := filepath.Join(target, filepath.FromSlash(rel))
+		files = append(files, file)
+
+		if valid && GetHash(file) != hash {
+			fmt.Printf("%v was modified, extracting %v again.\n", file, target)
+			valid = false
+		}
+	}
+
+	for rel, linkTarget := range index.Links {
+		file := filepath.Join(target, filepath.FromSlash(rel))
+		files = append(files, file)
+
+		if current, err := os.Readlink(file); valid && (err != nil || current != filepath.FromSlash(linkTarget)) {
+			fmt.Printf("%v was modified, extracting %v again.\n", file, target)
+			valid = false
+		}
+	}
+
+	return files, valid
+}
+
+// Extracts the downloaded archive and writes the list of what was extracted next to it
+func InstallArchive(bs *BootstrapSettings, archive, hash, format, target string) (*ArchiveIndex, error) {
+	if GetHash(archive) != strings.ToLower(hash) {
+		return nil, fmt.Errorf("%w: %v", ErrHashMismatch, archive)
+	}
+
+	index, err := ExtractArchive(archive, format, target)
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract %v: %w", archive, err)
+	}
+
+	for rel := range index.Files {
+		bs.Audit.FileDownloaded(Downloadable{Path: filepath.Join(target, filepath.FromSlash(rel))}, 0)
+	}
+
+	return index, SaveToCache(archive+".json", index)
+}
+
+func (m *LauncherManager) checkExtractedArchive(f ManifestFile, target string) ([]string, bool) {
+	return CheckExtractedArchive(m.getArchiveIndexPath(f), target)
+}
+
+// Extracts the archives that were missing or modified, once downloaded
+func (m *LauncherManager) ExtractPendingArchives() error {
+	for _, f := range m.pendingArchives {
+		_, err := InstallArchive(m.bSettings, m.getArchivePath(f), f.Hash, f.GetArchiveFormat(), filepath.Join(m.GetPath(), f.Path))
+		if err != nil {
+			return err
+		}
+	}
+
+	m.pendingArchives = nil
+
+	return nil
+}
+
+// Removes the archives that neither the current version nor the retained ones use
+func (m *LauncherManager) pruneArchives() error {
+	used := map[string]bool{}
+
+	manifests := []*LauncherManifest{m.launcherManifest}
+	versions, err := ListRetainedVersions(m.bSettings)
+	if err != nil {
+		return err
+	}
+
+	for _, v := range versions {
+		if manifest, err := LoadRetainedManifest(m.bSettings, v.Version); err == nil {
+			manifests = append(manifests, manifest)
+		}
+	}
+
+	for _, manifest := range manifests {
+		for _, f := range manifest.Files {
+			if f.Type == "archive" {
+				used[strings.ToLower(f.Hash)] = true
+			}
+		}
+	}
+
+	dir := filepath.Join(m.bSettings.LauncherPath, ".cache", ARCHIVES_CACHE_DIR)
+	entries, err := os.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	for _, e := range entries {
+		if !used[strings.TrimSuffix(e.Name(), ".json")] {
+			if err := os.RemoveAll(filepath.Join(dir, e.Name())); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// The path of the entry in the target folder, archives can't write outside of it
+func getArchiveEntryPath(target, name string) (string, string, error) {
+	name = strings.ReplaceAll(name, "\\", "/")
+	clean := path.Clean(name)
+
+	if path.IsAbs(name) || clean == ".." || strings.HasPrefix(clean, "../") || strings.Contains(name, ":") {
+		return "", "", fmt.Errorf("%w: %v", ErrUnsafeArchiveEntry, name)
+	}
+
+	return clean, filepath.Join(target, filepath.FromSlash(clean)), nil
+}
+
+// What was extracted so far, the links are only created once all the files are extracted
+// so that no file is written through one of them
+type archiveExtraction struct {
+	target string
+	index  *ArchiveIndex
+	size   int64
+	links  map[string]string
+}
+
+func (e *archiveExtraction) dir(name string) error {
+	_, dest, err := getArchiveEntryPath(e.target, name)
+	if err != nil {
+		return err
+	}
+
+	// Whatever was there before, i.e. a link of a previous extraction, must not be written through
+	if err := RemoveStaleLinks(e.target, []string{dest}, nil); err != nil {
+		return err
+	}
+
+	return os.MkdirAll(dest, DIR_MODE)
+}
+
+func (e *archiveExtraction) file(name string, r io.Reader, executable bool) error {
+	rel, dest, err := getArchiveEntryPath(e.target, name)
+	if err != nil {
+		return err
+	}
+
+	if err := RemoveStaleLinks(e.target, []string{dest}, nil); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dest), DIR_MODE); err != nil {
+		return err
+	}
+
+	// Whatever was there, i.e. a folder, is replaced
+	if err := os.RemoveAll(dest); err != nil {
+		return err
+	}
+
+	mode := FILE_MODE
+	if executable {
+		mode = EXECUTABLE_MODE
+	}
+
+	out, err := os.OpenFile(dest, os.O_CREATE|os.O_EXCL|os.O_WRONLY, mode)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	// The sizes given by the archive can't be trusted, only what is written is counted
+	h := sha256.New()
+	written, err := io.Copy(io.MultiWriter(out, h), io.LimitReader(r, ARCHIVE_MAX_EXTRACTED_SIZE-e.size+1))
+	e.size += written
+	if err != nil {
+		return err
+	} else if e.size > ARCHIVE_MAX_EXTRACTED_SIZE {
+		return fmt.Errorf("%w: more than %v bytes once extracted", ErrArchiveTooLarge, ARCHIVE_MAX_EXTRACTED_SIZE)
+	}
+
+	if _, err := EnsureFileMode(dest, mode); err != nil {
+		return err
+	}
+
+	e.index.Files[rel] = fmt.Sprintf("%x", h.Sum(nil))
+
+	return nil
+}
+
+func (e *archiveExtraction) link(name, linkTarget string) error {
+	rel, _, err := getArchiveEntryPath(e.target, name)
+	if err != nil {
+		return err
+	}
+
+	e.links[rel] = linkTarget
+
+	return nil
+}
+
+// Creates the links, a file of the archive can't be in the folder of one of them
+func (e *archiveExtraction) finish() error {
+	for rel := range e.index.Files {
+		for parent := path.Dir(rel); parent != "."; parent = path.Dir(parent) {
+			if _, ok := e.links[parent]; ok {
+				return fmt.Errorf("%w: %v is in the link %v", ErrUnsafeArchiveEntry, rel, parent)
+			}
+		}
+	}
+
+	for rel, linkTarget := range e.links {
+		if _, err := EnsureSymlink(e.target, filepath.Join(e.target, filepath.FromSlash(rel)), linkTarget); err != nil {
+			return err
+		}
+
+		e.index.Links[rel] = linkTarget
+	}
+
+	return nil
+}
+
+func ExtractArchive(archive, format, target string) (*ArchiveIndex, error) {
+	e := &archiveExtraction{
+		target: target,
+		index:  &ArchiveIndex{Files: map[string]string{}, Links: map[string]string{}},
+		links:  map[string]string{},
+	}
+
+	if err := os.MkdirAll(target, DIR_MODE); err != nil {
+		return nil, err
+	}
+
+	var err error
+	switch format {
+	case ArchiveFormatZip:
+		err = extractZip(archive, e)
+	case ArchiveFormatTarGz, ArchiveFormatTarZst:
+		err = extractTar(archive, format, e)
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownArchiveFormat, format)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return e.index, e.finish()
+}
+
+func extractZip(archive string, e *archiveExtraction) error {
+	r, err := zip.OpenReader(archive)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	for _, zf := range r.File {
+		if zf.FileInfo().IsDir() {
+			if err := e.dir(zf.Name); err != nil {
+				return err
+			}
+			continue
+		}
+
+		rc, err := zf.Open()
+		if err != nil {
+			return err
+		}
+
+		// Zip links store their target as the content of the entry
+		if zf.Mode()&os.ModeSymlink != 0 {
+			linkTarget, err := io.ReadAll(io.LimitReader(rc, 4096))
+			rc.Close()
+			if err != nil {
+				return err
+			}
+
+			if err := e.link(zf.Name, string(linkTarget)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		err = e.file(zf.Name, rc, zf.Mode()&0111 != 0)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func extractTar(archive, format string, e *archiveExtraction) error {
+	f, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var r io.Reader
+	if format == ArchiveFormatTarGz {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+
+		r = gz
+	} else {
+		zr, err := zstd.NewReader(f)
+		if err != nil {
+			return err
+		}
+		defer zr.Close()
+
+		r = zr
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			err = e.dir(hdr.Name)
+		case tar.TypeReg:
+			err = e.file(hdr.Name, tr, hdr.Mode&0111 != 0)
+		case tar.TypeSymlink:
+			err = e.link(hdr.Name, hdr.Linkname)
+		default:
+			fmt.Printf("Skipping %v in %v, unsupported entry type.\n", hdr.Name, filepath.Base(archive))
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+}
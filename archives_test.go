@@ -0,0 +1,232 @@
+/**
+ * Spectrum-Bootstrap - A bootstrap for Minecraft launchers
+ * Copyright (C) 2023-2024 - Oxodao
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ **/
+
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGetArchiveEntryPath(t *testing.T) {
+	target := filepath.Join(string(filepath.Separator), "target")
+
+	tests := []struct {
+		name  string
+		entry string
+		rel   string
+		err   error
+	}{
+		{"file", "bin/java", "bin/java", nil},
+		{"folder", "lib/", "lib", nil},
+		{"dot", "./bin/java", "bin/java", nil},
+		{"up inside", "lib/../bin/java", "bin/java", nil},
+		{"backslashes", "bin\\java.exe", "bin/java.exe", nil},
+		{"up outside", "../evil", "", ErrUnsafeArchiveEntry},
+		{"up outside after a name", "link/../../evil", "", ErrUnsafeArchiveEntry},
+		{"only up", "..", "", ErrUnsafeArchiveEntry},
+		{"absolute", "/etc/passwd", "", ErrUnsafeArchiveEntry},
+		{"windows drive", "C:/Windows/evil.dll", "", ErrUnsafeArchiveEntry},
+		{"windows backslashes up", "..\\evil", "", ErrUnsafeArchiveEntry},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rel, dest, err := getArchiveEntryPath(target, tt.entry)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("expected the error %v, got %v", tt.err, err)
+			} else if err != nil {
+				return
+			}
+
+			if rel != tt.rel {
+				t.Errorf("expected %q, got %q", tt.rel, rel)
+			}
+
+			if dest != filepath.Join(target, filepath.FromSlash(tt.rel)) {
+				t.Errorf("expected %v to be in %v", dest, target)
+			}
+		})
+	}
+}
+
+type testArchiveEntry struct {
+	name string
+	link string
+	body string
+}
+
+func writeTestTarGz(t *testing.T, entries []testArchiveEntry) string {
+	archive := filepath.Join(t.TempDir(), "archive.tar.gz")
+	f, err := os.Create(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	for _, e := range entries {
+		hdr := &tar.Header{Name: e.name, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(e.body))}
+		if len(e.link) > 0 {
+			hdr = &tar.Header{Name: e.name, Typeflag: tar.TypeSymlink, Linkname: e.link}
+		}
+
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := tw.Write([]byte(e.body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return archive
+}
+
+func writeTestZip(t *testing.T, entries []testArchiveEntry) string {
+	archive := filepath.Join(t.TempDir(), "archive.zip")
+	f, err := os.Create(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	zw := zip.NewWriter(f)
+	for _, e := range entries {
+		hdr := &zip.FileHeader{Name: e.name}
+		hdr.SetMode(0644)
+
+		body := e.body
+		if len(e.link) > 0 {
+			hdr.SetMode(os.ModeSymlink | 0777)
+			body = e.link
+		}
+
+		w, err := zw.CreateHeader(hdr)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := w.Write([]byte(body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return archive
+}
+
+func TestExtractArchive(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []testArchiveEntry
+		files   []string
+		links   []string
+		err     error
+	}{
+		{
+			"files and links",
+			[]testArchiveEntry{{name: "bin/java", body: "java"}, {name: "lib/java", link: "../bin/java"}},
+			[]string{"bin/java"}, []string{"lib/java"}, nil,
+		},
+		{
+			"link before its target",
+			[]testArchiveEntry{{name: "lib/java", link: "../bin/java"}, {name: "bin/java", body: "java"}},
+			[]string{"bin/java"}, []string{"lib/java"}, nil,
+		},
+		{
+			"file written through a link",
+			[]testArchiveEntry{{name: "up", link: "."}, {name: "up/evil", body: "evil"}},
+			nil, nil, ErrUnsafeArchiveEntry,
+		},
+		{
+			"chained links",
+			[]testArchiveEntry{{name: "a/up", link: ".."}, {name: "a/up/esc", link: ".."}, {name: "a/up/esc/evil", body: "evil"}},
+			nil, nil, ErrUnsafeArchiveEntry,
+		},
+		{
+			"link outside",
+			[]testArchiveEntry{{name: "evil", link: "../outside"}},
+			nil, nil, ErrUnsafeLink,
+		},
+		{
+			"entry outside",
+			[]testArchiveEntry{{name: "../evil", body: "evil"}},
+			nil, nil, ErrUnsafeArchiveEntry,
+		},
+	}
+
+	formats := []struct {
+		format string
+		write  func(t *testing.T, entries []testArchiveEntry) string
+	}{
+		{ArchiveFormatTarGz, writeTestTarGz},
+		{ArchiveFormatZip, writeTestZip},
+	}
+
+	for _, f := range formats {
+		for _, tt := range tests {
+			t.Run(f.format+"/"+tt.name, func(t *testing.T) {
+				dir := t.TempDir()
+				target := filepath.Join(dir, "target")
+
+				index, err := ExtractArchive(f.write(t, tt.entries), f.format, target)
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("expected the error %v, got %v", tt.err, err)
+				}
+
+				if _, err := os.Lstat(filepath.Join(dir, "evil")); !os.IsNotExist(err) {
+					t.Errorf("a file was written outside of the target")
+				}
+
+				if tt.err != nil {
+					return
+				}
+
+				for _, file := range tt.files {
+					if _, ok := index.Files[file]; !ok {
+						t.Errorf("expected %v in the extracted files, got %v", file, index.Files)
+					}
+				}
+
+				for _, link := range tt.links {
+					if _, ok := index.Links[link]; !ok {
+						t.Errorf("expected %v in the extracted links, got %v", link, index.Links)
+					}
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,224 @@
+/**
+ * Spectrum-Bootstrap - A bootstrap for Minecraft launchers
+ * Copyright (C) 2023-2024 - Oxodao
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ **/
+
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"time"
+)
+
+const AUDIT_LOG_FILENAME = "audit.log"
+
+const (
+	AuditRunStarted      = "run_started"
+	AuditManifest        = "manifest"
+	AuditFileAdded       = "file_added"
+	AuditFileReplaced    = "file_replaced"
+	AuditFileRemoved     = "file_removed"
+	AuditLauncherStarted = "launcher_started"
+	AuditRunFinished     = "run_finished"
+)
+
+const (
+	AuditOutcomeSuccess = "success"
+	AuditOutcomeFailure = "failure"
+)
+
+// One line of the audit log
+// Every event of a single run shares the same run id
+type AuditEvent struct {
+	Time         time.Time `json:"time"`
+	Run          string    `json:"run"`
+	Event        string    `json:"event"`
+	Name         string    `json:"name,omitempty"`
+	Version      string    `json:"version,omitempty"`
+	Path         string    `json:"path,omitempty"`
+	Hash         string    `json:"hash,omitempty"`
+	PreviousHash string    `json:"previous_hash,omitempty"`
+	DurationMs   int64     `json:"duration_ms,omitempty"`
+	Outcome      string    `json:"outcome,omitempty"`
+	Error        string    `json:"error,omitempty"`
+}
+
+// Append-only JSON-lines log of everything the bootstrap changes on disk
+// It is stored in the launcher path so that support can ask for it
+//
+// All methods are safe to call on a nil *AuditLog so that callers
+// don't need to care whether the log could be opened or not
+type AuditLog struct {
+	mtx      sync.Mutex
+	file     *os.File
+	run      string
+	started  time.Time
+	finished bool
+}
+
+func OpenAuditLog(bs *BootstrapSettings) (*AuditLog, error) {
+	f, err := os.OpenFile(
+		filepath.Join(bs.LauncherPath, AUDIT_LOG_FILENAME),
+		os.O_APPEND|os.O_CREATE|os.O_WRONLY,
+		0644,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	runId := make([]byte, 8)
+	if _, err := rand.Read(runId); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	log := &AuditLog{
+		file:    f,
+		run:     hex.EncodeToString(runId),
+		started: time.Now(),
+	}
+
+	log.write(AuditEvent{
+		Event:   AuditRunStarted,
+		Name:    bs.Brand,
+		Version: BOOTSTRAP_VERSION + " (" + runtime.GOOS + "/" + runtime.GOARCH + ")",
+	})
+
+	return log, nil
+}
+
+func (l *AuditLog) write(evt AuditEvent) {
+	if l == nil {
+		return
+	}
+
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	if l.file == nil {
+		return
+	}
+
+	evt.Time = time.Now()
+	evt.Run = l.run
+
+	data, err := json.Marshal(evt)
+	if err != nil {
+		fmt.Println("Failed to serialize audit event:", err)
+		return
+	}
+
+	if _, err := l.file.Write(append(data, '\n')); err != nil {
+		fmt.Println("Failed to write audit event:", err)
+	}
+}
+
+// Records the version of a manifest used for this run
+func (l *AuditLog) Manifest(name, version string) {
+	l.write(AuditEvent{
+		Event:   AuditManifest,
+		Name:    name,
+		Version: version,
+	})
+}
+
+// Records a file that was just written by the bootstrap
+func (l *AuditLog) FileDownloaded(f Downloadable, duration time.Duration) {
+	if l == nil {
+		return
+	}
+
+	evt := AuditEvent{
+		Event:        AuditFileAdded,
+		Path:         f.Path,
+		Hash:         GetHash(f.Path),
+		PreviousHash: f.PreviousHash,
+		DurationMs:   duration.Milliseconds(),
+	}
+
+	if len(f.PreviousHash) > 0 {
+		evt.Event = AuditFileReplaced
+	}
+
+	l.write(evt)
+}
+
+// Records a file that is about to be removed by the bootstrap
+func (l *AuditLog) FileRemoved(path string) {
+	if l == nil {
+		return
+	}
+
+	l.write(AuditEvent{
+		Event: AuditFileRemoved,
+		Path:  path,
+		Hash:  GetHash(path),
+	})
+}
+
+func (l *AuditLog) LauncherStarted(executable string) {
+	if l == nil {
+		return
+	}
+
+	l.write(AuditEvent{
+		Event:      AuditLauncherStarted,
+		Path:       executable,
+		DurationMs: time.Since(l.started).Milliseconds(),
+	})
+}
+
+// Records the outcome of the run and closes the log
+// Only the first call has an effect
+func (l *AuditLog) Finish(err error) {
+	if l == nil {
+		return
+	}
+
+	l.mtx.Lock()
+	if l.finished {
+		l.mtx.Unlock()
+		return
+	}
+	l.finished = true
+	l.mtx.Unlock()
+
+	evt := AuditEvent{
+		Event:      AuditRunFinished,
+		Outcome:    AuditOutcomeSuccess,
+		DurationMs: time.Since(l.started).Milliseconds(),
+	}
+
+	if err != nil {
+		evt.Outcome = AuditOutcomeFailure
+		evt.Error = err.Error()
+	}
+
+	l.write(evt)
+
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	l.file.Close()
+	l.file = nil
+}
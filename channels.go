@@ -0,0 +1,119 @@
+/**
+ * Spectrum-Bootstrap - A bootstrap for Minecraft launchers
+ * Copyright (C) 2023-2024 - Oxodao
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ **/
+
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+const DEFAULT_CHANNEL = "stable"
+
+var ErrUnknownChannel = errors.New("unknown update channel")
+
+func (bs *BootstrapSettings) GetDefaultChannel() string {
+	if len(bs.DefaultChannel) > 0 {
+		return bs.DefaultChannel
+	}
+
+	return DEFAULT_CHANNEL
+}
+
+func (bs *BootstrapSettings) GetChannelManifestURL(channel string) (string, bool) {
+	// launcher_manifest is always the default channel
+	// so that settings without channels keep working
+	// and that it can be overridden i.e. with --manifest
+	if channel == bs.GetDefaultChannel() && len(bs.ManifestURL) > 0 {
+		return bs.ManifestURL, true
+	}
+
+	url, ok := bs.Channels[channel]
+
+	return url, ok
+}
+
+// Picks the channel to use for this run and points ManifestURL to it
+// The requested channel (i.e. the --channel flag) takes precedence over the one
+// saved in the user config. When requested, it is saved for the next runs.
+// A manifest url given on the command line (i.e. --manifest) wins over both, for this run only
+func (bs *BootstrapSettings) SelectChannel(requested string, cfg *UserConfig) error {
+	if bs.Sources["launcher_manifest"] == SETTINGS_LAYER_COMMAND_LINE {
+		ignored := requested
+		if len(ignored) == 0 {
+			ignored = cfg.Channel
+		}
+
+		if len(ignored) > 0 && ignored != bs.GetDefaultChannel() {
+			fmt.Printf("Using the manifest given on the command line instead of the channel %v.\n", ignored)
+		}
+
+		bs.Channel = bs.GetDefaultChannel()
+
+		return nil
+	}
+
+	if len(requested) > 0 {
+		url, ok := bs.GetChannelManifestURL(requested)
+		if !ok {
+			return fmt.Errorf("%w: %v", ErrUnknownChannel, requested)
+		}
+
+		bs.Channel = requested
+		bs.ManifestURL = url
+
+		if cfg.Channel != requested {
+			cfg.Channel = requested
+			return cfg.Save(bs)
+		}
+
+		return nil
+	}
+
+	channel := cfg.Channel
+	if len(channel) == 0 {
+		channel = bs.GetDefaultChannel()
+	}
+
+	url, ok := bs.GetChannelManifestURL(channel)
+	if !ok {
+		// The channel the user chose is not available anymore
+		fmt.Printf("Channel %v is not available anymore, using %v.\n", channel, bs.GetDefaultChannel())
+
+		channel = bs.GetDefaultChannel()
+		url, ok = bs.GetChannelManifestURL(channel)
+		if !ok {
+			return fmt.Errorf("%w: %v", ErrUnknownChannel, channel)
+		}
+	}
+
+	bs.Channel = channel
+	bs.ManifestURL = url
+
+	return nil
+}
+
+// Name of the cached launcher manifest for the active channel
+// The default channel keeps the historical name
+func (bs *BootstrapSettings) GetLauncherManifestCacheName() string {
+	if bs.Channel == bs.GetDefaultChannel() {
+		return "launcher_manifest.json"
+	}
+
+	return "launcher_manifest_" + bs.Channel + ".json"
+}
@@ -0,0 +1,57 @@
+/**
+ * Spectrum-Bootstrap - A bootstrap for Minecraft launchers
+ * Copyright (C) 2023-2024 - Oxodao
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ **/
+
+package main
+
+import "testing"
+
+func TestSelectChannel(t *testing.T) {
+	tests := []struct {
+		name      string
+		requested string
+		saved     string
+		sources   map[string]string
+		channel   string
+		url       string
+	}{
+		{"default channel", "", "", nil, "stable", "https://example.com/stable.json"},
+		{"saved channel", "", "beta", nil, "beta", "https://example.com/beta.json"},
+		{"saved channel removed", "", "alpha", nil, "stable", "https://example.com/stable.json"},
+		{"manifest of a settings file", "", "beta", map[string]string{"launcher_manifest": "environment"}, "beta", "https://example.com/beta.json"},
+		{"manifest on the command line", "", "beta", map[string]string{"launcher_manifest": SETTINGS_LAYER_COMMAND_LINE}, "stable", "https://example.com/stable.json"},
+		{"manifest and channel on the command line", "beta", "", map[string]string{"launcher_manifest": SETTINGS_LAYER_COMMAND_LINE}, "stable", "https://example.com/stable.json"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bs := &BootstrapSettings{
+				ManifestURL: "https://example.com/stable.json",
+				Channels:    map[string]string{"beta": "https://example.com/beta.json"},
+				Sources:     tt.sources,
+			}
+
+			if err := bs.SelectChannel(tt.requested, &UserConfig{Channel: tt.saved}); err != nil {
+				t.Fatal(err)
+			}
+
+			if bs.Channel != tt.channel || bs.ManifestURL != tt.url {
+				t.Errorf("expected %v (%v), got %v (%v)", tt.channel, tt.url, bs.Channel, bs.ManifestURL)
+			}
+		})
+	}
+}
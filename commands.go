@@ -0,0 +1,421 @@
+/**
+ * Spectrum-Bootstrap - A bootstrap for Minecraft launchers
+ * Copyright (C) 2023-2024 - Oxodao
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ **/
+
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+var ErrUnknownCommand = errors.New("unknown command")
+
+// Commands run without the UI, i.e. `./bootstrap rollback`
+type Command struct {
+	Usage       string
+	Description string
+	Run         func(bs *BootstrapSettings, cfg *UserConfig, args []string) error
+
+	// Standalone commands don't need the settings nor the launcher folder
+	Standalone bool
+}
+
+var Commands = map[string]Command{
+	"versions": {
+		Usage:       "versions",
+		Description: "List the launcher versions kept on disk",
+		Run:         commandVersions,
+	},
+	"pin": {
+		Usage:       "pin <version>",
+		Description: "Always use the given launcher version, it must be kept on disk",
+		Run:         commandPin,
+	},
+	"unpin": {
+		Usage:       "unpin",
+		Description: "Go back to the latest launcher version",
+		Run:         commandUnpin,
+	},
+	"settings": {
+		Usage:       "settings",
+		Description: "Print the effective bootstrap settings and where they come from",
+		Run:         commandSettings,
+	},
+	"rebrand": {
+		Usage:       "rebrand [options]",
+		Description: "Write a copy of a bootstrap with different settings and icon",
+		Run:         commandRebrand,
+		Standalone:  true,
+	},
+	"keygen": {
+		Usage:       "keygen <private key file>",
+		Description: "Generate a key pair to sign payloads and manifests",
+		Run:         commandKeygen,
+		Standalone:  true,
+	},
+	"validate": {
+		Usage:       "validate <manifest file>",
+		Description: "Check a launcher manifest before publishing it",
+		Run:         commandValidate,
+		Standalone:  true,
+	},
+	"java": {
+		Usage:       "java",
+		Description: "List the java installations --java system can use",
+		Run:         commandJava,
+		Standalone:  true,
+	},
+	"rollback": {
+		Usage:       "rollback",
+		Description: "Pin the launcher version installed before the current one",
+		Run:         commandRollback,
+	},
+}
+
+func RunCommand(args []string) error {
+	cmd, ok := Commands[args[0]]
+	if !ok {
+		PrintCommandsUsage()
+		return fmt.Errorf("%w: %v", ErrUnknownCommand, args[0])
+	}
+
+	if cmd.Standalone {
+		return cmd.Run(nil, nil, args[1:])
+	}
+
+	settings := BootstrapSettings{}
+	userConfig, err := LoadBootstrapSettings(&settings, nil)
+	if err != nil {
+		return err
+	}
+
+	return cmd.Run(&settings, userConfig, args[1:])
+}
+
+func PrintCommandsUsage() {
+	names := []string{}
+	for name := range Commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Println("Available commands:")
+	for _, name := range names {
+		fmt.Printf("  %-20v %v\n", Commands[name].Usage, Commands[name].Description)
+	}
+}
+
+func commandVersions(bs *BootstrapSettings, cfg *UserConfig, args []string) error {
+	versions, err := ListRetainedVersions(bs)
+	if err != nil {
+		return err
+	}
+
+	if len(versions) == 0 {
+		fmt.Println("No launcher version installed yet.")
+		return nil
+	}
+
+	for i, v := range versions {
+		flags := ""
+		if i == 0 {
+			flags += " (installed)"
+		}
+
+		if v.Version == bs.PinnedVersion {
+			flags += " (pinned)"
+		}
+
+		fmt.Printf("%v\t%v%v\n", v.Version, v.InstalledAt.Format("2006-01-02 15:04:05"), flags)
+	}
+
+	return nil
+}
+
+func commandPin(bs *BootstrapSettings, cfg *UserConfig, args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: pin <version>")
+	}
+
+	if err := PinLauncherVersion(bs, cfg, args[0]); err != nil {
+		return err
+	}
+
+	fmt.Printf("Launcher version %v pinned.\n", args[0])
+
+	return nil
+}
+
+func commandUnpin(bs *BootstrapSettings, cfg *UserConfig, args []string) error {
+	cfg.PinnedVersion = ""
+	if err := cfg.Save(bs); err != nil {
+		return err
+	}
+
+	fmt.Println("The latest launcher version will be used.")
+
+	return nil
+}
+
+func commandRollback(bs *BootstrapSettings, cfg *UserConfig, args []string) error {
+	version, err := RollbackLauncherVersion(bs, cfg)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Rolled back to launcher version %v, run `unpin` to get the updates again.\n", version)
+
+	return nil
+}
+
+func commandSettings(bs *BootstrapSettings, cfg *UserConfig, args []string) error {
+	data, err := json.Marshal(bs)
+	if err != nil {
+		return err
+	}
+
+	values := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &values); err != nil {
+		return err
+	}
+
+	// Also listing the settings that are not set anywhere
+	t := reflect.TypeOf(BootstrapSettings{})
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if _, ok := values[name]; !ok && len(name) > 0 && name != "-" {
+			values[name] = json.RawMessage("null")
+		}
+	}
+
+	keys := []string{}
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		source, ok := bs.Sources[k]
+		if !ok {
+			source = "default"
+		}
+
+		fmt.Printf("%v = %v\n    from %v\n", k, string(values[k]), source)
+	}
+
+	fmt.Println()
+	if len(bs.Profile) > 0 {
+		fmt.Printf("Profile: %v\n", bs.Profile)
+	}
+	fmt.Printf("Launcher path: %v\n", bs.LauncherPath)
+	fmt.Printf("Channel: %v (%v)\n", bs.Channel, bs.ManifestURL)
+
+	platform := bs.GetPlatform()
+	if keys, err := platform.GetJavaOsKeys(bs.PlatformFallbacks); err == nil {
+		fmt.Printf("Platform: %v (java builds: %v)\n", platform, strings.Join(keys, ", "))
+	} else {
+		fmt.Printf("Platform: %v (%v)\n", platform, err)
+	}
+
+	return nil
+}
+
+func commandJava(bs *BootstrapSettings, cfg *UserConfig, args []string) error {
+	// Standalone, the settings are not loaded so --platform is read here
+	platform := DetectPlatform()
+	if len(*platformFlag) > 0 {
+		p, err := ParsePlatform(*platformFlag)
+		if err != nil {
+			return err
+		}
+		platform = p
+	}
+
+	homes := GetJavaHomes(&UserConfig{Java: JavaSourceSystem})
+	if len(homes) == 0 {
+		fmt.Println("No java installation found.")
+		return nil
+	}
+
+	for _, home := range homes {
+		java, err := ProbeJava(home)
+		if err != nil {
+			fmt.Printf("%v\tunusable: %v\n", home, err)
+			continue
+		}
+
+		flags := ""
+		if !java.MatchesArch(platform.Arch) {
+			flags = " (other architecture)"
+		}
+
+		fmt.Printf("%v\t%v %v%v\n", home, java.Version, java.Arch, flags)
+	}
+
+	return nil
+}
+
+func commandRebrand(bs *BootstrapSettings, cfg *UserConfig, args []string) error {
+	fs := flag.NewFlagSet("rebrand", flag.ContinueOnError)
+	in := fs.String("in", "", "The bootstrap to rebrand, defaults to this one")
+	out := fs.String("out", "", "Where to write the rebranded bootstrap")
+	settingsFile := fs.String("settings", "", "A bs_settings.json whose keys are added to the payload")
+	manifest := fs.String("manifest", "", "The launcher manifest url")
+	brand := fs.String("brand", "", "The launcher brand")
+	folderName := fs.String("folder-name", "", "The launcher folder name")
+	icon := fs.String("icon", "", "A png icon for the bootstrap window")
+	key := fs.String("key", "", "The private key to sign the payload with (see keygen)")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if len(*out) == 0 {
+		return errors.New("usage: rebrand --out <file> [--in <file>] [--settings <file>] [--manifest <url>] [--brand <name>] [--folder-name <name>] [--icon <png>] [--key <file>]")
+	}
+
+	if len(*in) == 0 {
+		executable, err := os.Executable()
+		if err != nil {
+			return err
+		}
+
+		*in = executable
+	}
+
+	// Starting from the current payload so that rebranding twice keeps the previous changes
+	previous, _, err := ReadPayload(*in)
+	if err != nil {
+		return err
+	}
+
+	values := map[string]json.RawMessage{}
+	payload := &Payload{}
+	if previous != nil {
+		payload.Icon = previous.Icon
+
+		if len(previous.Settings) > 0 {
+			if err := json.Unmarshal(previous.Settings, &values); err != nil {
+				return ErrPayloadCorrupted
+			}
+		}
+	}
+
+	if len(*settingsFile) > 0 {
+		layer, err := readSettingsFile("settings", *settingsFile)
+		if err != nil {
+			return err
+		}
+
+		if layer == nil {
+			return fmt.Errorf("%v not found", *settingsFile)
+		}
+
+		for k, v := range layer.Values {
+			values[k] = v
+		}
+	}
+
+	for k, v := range map[string]string{"launcher_manifest": *manifest, "launcher_brand": *brand, "launcher_foldername": *folderName} {
+		if len(v) > 0 {
+			values[k], _ = json.Marshal(v)
+		}
+	}
+
+	// The trusted keys can't be changed by a payload, it would be pointless
+	delete(values, "payload_public_keys")
+
+	payload.Settings, err = json.Marshal(values)
+	if err != nil {
+		return err
+	}
+
+	if len(*icon) > 0 {
+		payload.Icon, err = os.ReadFile(*icon)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(*key) > 0 {
+		privateKey, err := LoadPrivateKey(*key)
+		if err != nil {
+			return err
+		}
+
+		payload.Sign(privateKey)
+	}
+
+	if err := WriteExecutableWithPayload(*in, *out, payload); err != nil {
+		return err
+	}
+
+	fmt.Printf("Rebranded bootstrap written to %v.\n", *out)
+
+	return nil
+}
+
+func commandKeygen(bs *BootstrapSettings, cfg *UserConfig, args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: keygen <private key file>")
+	}
+
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return err
+	}
+
+	err = os.WriteFile(args[0], []byte(base64.StdEncoding.EncodeToString(privateKey.Seed())+"\n"), 0600)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Private key written to %v, keep it secret.\n", args[0])
+	fmt.Printf("Public key: %v\n", base64.StdEncoding.EncodeToString(publicKey))
+
+	return nil
+}
+
+func commandValidate(bs *BootstrapSettings, cfg *UserConfig, args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: validate <manifest file>")
+	}
+
+	data, err := os.ReadFile(args[0])
+	if err != nil {
+		return err
+	}
+
+	var manifest LauncherManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return err
+	}
+
+	fmt.Printf("The manifest of version %v is valid (schema %v).\n", manifest.Version, MANIFEST_SCHEMA_VERSION)
+
+	return nil
+}
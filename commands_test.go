@@ -0,0 +1,50 @@
+/**
+ * Spectrum-Bootstrap - A bootstrap for Minecraft launchers
+ * Copyright (C) 2023-2024 - Oxodao
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ **/
+
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRunCommandJava(t *testing.T) {
+	previous := *platformFlag
+	defer func() { *platformFlag = previous }()
+
+	tests := []struct {
+		name     string
+		platform string
+		err      error
+	}{
+		{"detected platform", "", nil},
+		{"forced platform", "linux/arm64", nil},
+		{"invalid platform", "linux/sparc", ErrInvalidPlatform},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			*platformFlag = tt.platform
+
+			// Standalone, it runs without any settings
+			if err := RunCommand([]string{"java"}); !errors.Is(err, tt.err) {
+				t.Errorf("expected the error %v, got %v", tt.err, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,134 @@
+/**
+ * Spectrum-Bootstrap - A bootstrap for Minecraft launchers
+ * Copyright (C) 2023-2024 - Oxodao
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ **/
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// Windows environment variables are case-insensitive
+func normalizeEnvName(name string) string {
+	if runtime.GOOS == "windows" {
+		return strings.ToUpper(name)
+	}
+
+	return name
+}
+
+// Patterns can use wildcards, i.e. "JAVA_*"
+func matchesEnvPattern(patterns []string, name string) bool {
+	name = normalizeEnvName(name)
+
+	for _, p := range patterns {
+		if matched, _ := path.Match(normalizeEnvName(p), name); matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Builds the environment of the launcher from the bootstrap's one
+// - Without an allow list, every variable is inherited
+// - The deny list is applied after the allow list
+// - The variables set by the manifest are added last and can use the ${var} placeholders
+func BuildEnvironment(settings EnvironmentSettings, inherited []string, variables map[string]string) ([]string, error) {
+	env := map[string]string{}
+	names := map[string]string{}
+	result := []string{}
+
+	for _, kv := range inherited {
+		name, value, ok := strings.Cut(kv, "=")
+		if !ok {
+			continue
+		}
+
+		// Windows has some hidden variables like "=C:=C:\\", we keep them as they are
+		if len(name) == 0 {
+			result = append(result, kv)
+			continue
+		}
+
+		if len(settings.Allow) > 0 && !matchesEnvPattern(settings.Allow, name) {
+			continue
+		}
+
+		if matchesEnvPattern(settings.Deny, name) {
+			continue
+		}
+
+		env[name] = value
+		names[normalizeEnvName(name)] = name
+	}
+
+	for name, value := range settings.Set {
+		// Replacing the inherited one even if the case is not the same on Windows
+		if existing, ok := names[normalizeEnvName(name)]; ok {
+			delete(env, existing)
+		}
+
+		expanded, err := ExpandTemplate(value, variables)
+		if err != nil {
+			return nil, fmt.Errorf("environment variable %v: %w", name, err)
+		}
+
+		env[name] = expanded
+		names[normalizeEnvName(name)] = name
+	}
+
+	for name, value := range env {
+		result = append(result, name+"="+value)
+	}
+
+	sort.Strings(result)
+
+	return result, nil
+}
+
+// The defaults are the variables needed by the runtime, the manifest can still override them
+func (m *LauncherManager) GetEnvironment(variables map[string]string, defaults map[string]string) ([]string, error) {
+	settings := m.launcherManifest.Env
+
+	if len(defaults) > 0 {
+		set := map[string]string{}
+		for name, value := range defaults {
+			// They are not templates
+			set[name] = strings.ReplaceAll(value, "$", "$$")
+		}
+
+		for name, value := range settings.Set {
+			for existing := range defaults {
+				if normalizeEnvName(existing) == normalizeEnvName(name) {
+					delete(set, existing)
+				}
+			}
+
+			set[name] = value
+		}
+
+		settings.Set = set
+	}
+
+	return BuildEnvironment(settings, os.Environ(), variables)
+}
@@ -22,6 +22,8 @@ package main
 
 import (
 	"os"
+	"path/filepath"
+	"runtime"
 
 	"github.com/kirsle/configdir"
 )
@@ -36,7 +38,7 @@ func GetLauncherDirectory(s *BootstrapSettings) (string, error) {
 	if err != nil && !os.IsNotExist(err) {
 		return "", err
 	} else if err != nil {
-		err = os.MkdirAll(lp, os.ModePerm)
+		err = os.MkdirAll(lp, DIR_MODE)
 		if err != nil {
 			return "", err
 		}
@@ -44,3 +46,24 @@ func GetLauncherDirectory(s *BootstrapSettings) (string, error) {
 
 	return lp, nil
 }
+
+// Settings set by the system administrator, for every user of the computer
+func GetPolicySettingsPath(folderName string) string {
+	if len(folderName) == 0 {
+		return ""
+	}
+
+	switch runtime.GOOS {
+	case "windows":
+		programData := os.Getenv("ProgramData")
+		if len(programData) == 0 {
+			return ""
+		}
+
+		return filepath.Join(programData, folderName, SETTINGS_FILENAME)
+	case "darwin":
+		return filepath.Join("/Library", "Application Support", folderName, SETTINGS_FILENAME)
+	default:
+		return ""
+	}
+}
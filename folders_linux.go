@@ -40,7 +40,7 @@ func GetLauncherDirectory(s *BootstrapSettings) (string, error) {
 	if err != nil && !os.IsNotExist(err) {
 		return "", err
 	} else if err != nil {
-		err = os.MkdirAll(lp, os.ModePerm)
+		err = os.MkdirAll(lp, DIR_MODE)
 		if err != nil {
 			return "", err
 		}
@@ -48,3 +48,12 @@ func GetLauncherDirectory(s *BootstrapSettings) (string, error) {
 
 	return lp, nil
 }
+
+// Settings set by the system administrator, for every user of the computer
+func GetPolicySettingsPath(folderName string) string {
+	if len(folderName) == 0 {
+		return ""
+	}
+
+	return filepath.Join("/etc", folderName, SETTINGS_FILENAME)
+}
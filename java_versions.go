@@ -0,0 +1,160 @@
+/**
+ * Spectrum-Bootstrap - A bootstrap for Minecraft launchers
+ * Copyright (C) 2023-2024 - Oxodao
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ **/
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+// Picks the most recently released build instead of the first one of the manifest
+const JAVA_VERSION_LATEST = "latest"
+
+var (
+	ErrInvalidJavaConstraint = errors.New("invalid java version constraint")
+	ErrNoJavaVersionMatch    = errors.New("no java version matches the constraint")
+)
+
+// The build of a runtime that was installed last, next to its folder
+type InstalledRuntime struct {
+	Component string `json:"component"`
+	Os        string `json:"os"`
+	Version   string `json:"version"`
+	Released  string `json:"released,omitempty"`
+
+	// The hash of the archive it was extracted from, for the archive source
+	Archive string `json:"archive,omitempty"`
+}
+
+func getJavaReleaseTime(v MainJavaManifestVersion) time.Time {
+	released, err := time.Parse(time.RFC3339, v.Version.Released)
+	if err != nil {
+		return time.Time{}
+	}
+
+	return released
+}
+
+// Picks the build of the runtime matching the constraint of the manifest:
+// - nothing: the first one this installation is part of the rollout, as listed by the java manifest
+// - "latest": the last released one
+// - the name of a build: this one, even when it is not rolled out to us
+// - a semver range (i.e. "~17.0.8" or ">=17, <18"): the highest matching one
+//
+// Builds still being rolled out are only picked for the installations part of it,
+// the others stay on the previous ones
+func (i *Installation) SelectJavaVersionConstraint(versions []MainJavaManifestVersion, constraint string) (MainJavaManifestVersion, error) {
+	if len(constraint) == 0 {
+		version, ok := i.SelectJavaVersion(versions)
+		if !ok {
+			return version, ErrNoJavaVersionForOs
+		}
+
+		return version, nil
+	}
+
+	for _, v := range versions {
+		if v.Version.Name == constraint {
+			return v, nil
+		}
+	}
+
+	candidates := []MainJavaManifestVersion{}
+
+	if constraint == JAVA_VERSION_LATEST {
+		candidates = append(candidates, versions...)
+		sort.SliceStable(candidates, func(a, b int) bool {
+			return getJavaReleaseTime(candidates[a]).After(getJavaReleaseTime(candidates[b]))
+		})
+	} else {
+		c, err := semver.NewConstraint(constraint)
+		if err != nil {
+			return MainJavaManifestVersion{}, fmt.Errorf("%w: %q", ErrInvalidJavaConstraint, constraint)
+		}
+
+		parsed := map[string]*semver.Version{}
+		for _, v := range versions {
+			// Names like 1.8.0_51 can't be compared, they are only usable by their exact name
+			sv, err := semver.NewVersion(v.Version.Name)
+			if err != nil || !c.Check(sv) {
+				continue
+			}
+
+			parsed[v.Version.Name] = sv
+			candidates = append(candidates, v)
+		}
+
+		sort.SliceStable(candidates, func(a, b int) bool {
+			va, vb := parsed[candidates[a].Version.Name], parsed[candidates[b].Version.Name]
+			if va.Equal(vb) {
+				return getJavaReleaseTime(candidates[a]).After(getJavaReleaseTime(candidates[b]))
+			}
+
+			return va.GreaterThan(vb)
+		})
+	}
+
+	if len(candidates) == 0 {
+		return MainJavaManifestVersion{}, fmt.Errorf("%w: %q", ErrNoJavaVersionMatch, constraint)
+	}
+
+	// The candidates are sorted from the newest to the oldest
+	version, _ := i.SelectJavaVersion(candidates)
+
+	return version, nil
+}
+
+func (m *JvmManager) getInstalledPath() string {
+	return filepath.Join(m.bSettings.RuntimePath, "runtime", m.runtime.Component, m.os+".json")
+}
+
+func (m *JvmManager) GetInstalled() *InstalledRuntime {
+	installed, err := LoadFromCache[InstalledRuntime](m.getInstalledPath())
+	if err != nil {
+		return nil
+	}
+
+	return installed
+}
+
+// Records the build that was installed, once its files are downloaded
+func (m *JvmManager) SaveInstalled() error {
+	if m.system != nil {
+		return nil
+	}
+
+	installed := InstalledRuntime{
+		Component: m.runtime.Component,
+		Os:        m.os,
+		Version:   m.version.Version.Name,
+		Released:  m.version.Version.Released,
+	}
+
+	if m.archive != nil {
+		installed.Archive = strings.ToLower(m.archive.Hash)
+	}
+
+	return SaveToCache(m.getInstalledPath(), installed)
+}
@@ -0,0 +1,75 @@
+/**
+ * Spectrum-Bootstrap - A bootstrap for Minecraft launchers
+ * Copyright (C) 2023-2024 - Oxodao
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ **/
+
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSelectJavaVersionConstraint(t *testing.T) {
+	version := func(name, released string, rollout *Rollout) MainJavaManifestVersion {
+		v := MainJavaManifestVersion{Availability: rollout}
+		v.Version.Name = name
+		v.Version.Released = released
+
+		return v
+	}
+
+	versions := []MainJavaManifestVersion{
+		version("17.0.8", "2023-07-18T00:00:00Z", nil),
+		version("17.0.10", "2024-01-16T00:00:00Z", &Rollout{Progress: 0}),
+		version("17.0.9", "2023-10-17T00:00:00Z", nil),
+		version("21.0.1", "2023-10-17T00:00:00Z", nil),
+		version("1.8.0_51", "2015-07-14T00:00:00Z", nil),
+	}
+
+	installation := &Installation{RolloutBucket: 10}
+
+	tests := []struct {
+		name       string
+		constraint string
+		expected   string
+		err        error
+	}{
+		{"no constraint", "", "17.0.8", nil},
+		{"exact name", "17.0.9", "17.0.9", nil},
+		{"exact name not rolled out", "17.0.10", "17.0.10", nil},
+		{"name that isn't semver", "1.8.0_51", "1.8.0_51", nil},
+		{"range skips the rollout", "~17.0", "17.0.9", nil},
+		{"range", ">=17.0.8, <18", "17.0.9", nil},
+		{"major", "21", "21.0.1", nil},
+		{"latest released", JAVA_VERSION_LATEST, "17.0.9", nil},
+		{"no match", "~11", "", ErrNoJavaVersionMatch},
+		{"invalid", "not a version", "", ErrInvalidJavaConstraint},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := installation.SelectJavaVersionConstraint(versions, tt.constraint)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("expected the error %v, got %v", tt.err, err)
+			}
+
+			if v.Version.Name != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, v.Version.Name)
+			}
+		})
+	}
+}
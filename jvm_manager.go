@@ -25,7 +25,6 @@ import (
 	"os"
 	"path"
 	"path/filepath"
-	"runtime"
 	"slices"
 )
 
@@ -35,113 +34,144 @@ var (
 	ErrNoJavaVersionForOs = errors.New("java version for this os doesn't include the required component")
 )
 
+// One of the java runtimes of the manifest, see runtime_manager.go
 type JvmManager struct {
-	cachedMainManifest    *MainJavaManifest
 	cachedVersionManifest *JavaManifest
+	version               MainJavaManifestVersion
 
-	launcherManifest LauncherJavaManifest
-	os               string
-	bSettings        *BootstrapSettings
+	runtime   LauncherRuntime
+	os        string
+	bSettings *BootstrapSettings
+
+	// Set when the java of the player is used instead of a downloaded one
+	system *SystemJava
+
+	// Set when the runtime comes from an archive instead of the java manifest, see runtime_archives.go
+	archive        *RuntimeArchive
+	pendingArchive bool
 }
 
-func GetJvmManager(bs *BootstrapSettings, launcherManifest LauncherJavaManifest) (*JvmManager, error) {
-	//#region Detecting os
-	// runtime.GOARCH = 386 amd64 amd64p32 arm arm64
-	os := runtime.GOOS
-	arch := runtime.GOARCH
-	if os == "linux" {
-		os = "linux"
-		if arch == "386" {
-			os += "-i386"
-		} else if arch != "amd64" && arch != "amd64p32" {
-			return nil, ErrFailedDetermineOs
-		}
-	} else if os == "darwin" {
-		os = "mac-os"
-		if arch == "arm64" {
-			os += "-arm64"
-		} else if arch != "amd64" {
-			return nil, ErrFailedDetermineOs
+// The first of the platform keys with a build of the component is used, see platform.go
+func GetJvmManager(bs *BootstrapSettings, mainManifest *MainJavaManifest, platformKeys []string, launcherRuntime LauncherRuntime) (*JvmManager, error) {
+	jvmManager := &JvmManager{
+		runtime:   launcherRuntime,
+		bSettings: bs,
+	}
+
+	// We load the manifest for the os/version
+	var availableVersions []MainJavaManifestVersion
+	err := ErrNoJavaForOs
+	for _, key := range platformKeys {
+		versions, ok := (*mainManifest)[key]
+		if !ok {
+			continue
 		}
-	} else if os == "windows" {
-		os = "windows"
-		if arch == "386" {
-			os += "-x86"
-		} else if arch == "amd64" || arch == "amd64p32" {
-			os += "-x64"
-		} else if arch == "arm64" {
-			os += "-arm64"
-		} else {
-			return nil, ErrFailedDetermineOs
+
+		err = ErrNoJavaVersionForOs
+		if len(versions[launcherRuntime.Component]) > 0 {
+			jvmManager.os = key
+			availableVersions = versions[launcherRuntime.Component]
+			break
 		}
-	} else {
-		return nil, ErrFailedDetermineOs
 	}
-	//#endregion
 
-	jvmManager := &JvmManager{
-		launcherManifest: launcherManifest,
-		bSettings:        bs,
-		os:               os,
+	if len(jvmManager.os) == 0 {
+		return nil, err
 	}
 
-	// We load the main manifest
-	mainManifest, err := GetOrCached[MainJavaManifest](
-		bs,
-		filepath.Join(bs.LauncherPath, ".cache", "main_java_manifest.json"),
-		launcherManifest.ManifestURL,
-	)
+	if jvmManager.os != platformKeys[0] {
+		fmt.Printf("No %v build of %v, using the %v one.\n", platformKeys[0], launcherRuntime.Component, jvmManager.os)
+	}
+
+	version, err := bs.Installation.SelectJavaVersionConstraint(availableVersions, launcherRuntime.Version)
 	if err != nil {
 		return nil, err
 	}
 
-	jvmManager.cachedMainManifest = mainManifest
-
-	// We load the manifest for the os/version
-	versions, ok := (*jvmManager.cachedMainManifest)[os]
-	if !ok {
-		return nil, ErrNoJavaForOs
-	}
+	jvmManager.version = version
 
-	version, ok := versions[launcherManifest.Component]
-	if !ok {
-		return nil, ErrNoJavaVersionForOs
-	}
 	versionManifest, err := GetOrCached[JavaManifest](
 		bs,
-		filepath.Join(bs.LauncherPath, ".cache", "java_"+os+"_"+launcherManifest.Component+".json"),
-		version[0].Manifest.Url, // @TODO: Check how versions are handled, should we DL the first or the last?
+		// Keyed by the build, the file list of another one must not be used when this one can't be fetched
+		filepath.Join(bs.RuntimePath, ".cache", "java_"+jvmManager.os+"_"+launcherRuntime.Component+"_"+GetUrlCacheKey(version.Manifest.Url)+".json"),
+		version.Manifest.Url,
 	)
 	if err != nil {
 		return nil, err
 	}
 
+	if installed := jvmManager.GetInstalled(); installed != nil && installed.Version != version.Version.Name {
+		fmt.Printf("Updating %v from %v to %v.\n", launcherRuntime.Component, installed.Version, version.Version.Name)
+	}
+
+	bs.Audit.Manifest("java/"+launcherRuntime.Component, version.Version.Name)
+
 	jvmManager.cachedVersionManifest = versionManifest
 
 	return jvmManager, nil
 }
 
 func (m *JvmManager) GetPath() string {
-	return path.Join(m.bSettings.LauncherPath, "runtime", m.launcherManifest.Component, m.os)
+	if m.system != nil {
+		return m.system.Home
+	}
+
+	return path.Join(m.bSettings.RuntimePath, "runtime", m.runtime.Component, m.os)
+}
+
+func (m *JvmManager) GetJavaExecutable() (string, error) {
+	if m.system != nil {
+		return m.system.GetJavaExecutable(), nil
+	}
+
+	return GetJavaExecutable(m.bSettings.GetPlatform().Os, m.GetPath())
 }
 
 // Returns a list of files to re-download
+// The java of the player is never modified
 func (m *JvmManager) ValidateInstallation() ([]Downloadable, error) {
+	if m.system != nil {
+		return []Downloadable{}, nil
+	} else if m.archive != nil {
+		return m.validateArchiveInstallation()
+	}
+
 	bp := m.GetPath()
 
 	filesToDownload := []Downloadable{}
 	fileList := []string{}
 
+	files, links := []string{}, []string{}
+	for k, v := range m.cachedVersionManifest.Files {
+		if v.Type == "link" {
+			links = append(links, filepath.Join(bp, k))
+		} else {
+			files = append(files, filepath.Join(bp, k))
+		}
+	}
+
+	if err := RemoveStaleLinks(bp, files, links); err != nil {
+		return nil, err
+	}
+
 	for k, v := range m.cachedVersionManifest.Files {
 		file := filepath.Join(bp, k)
 		fileList = append(fileList, file)
 
 		if v.Type == "directory" {
-			err := os.MkdirAll(file, os.ModePerm)
+			err := os.MkdirAll(file, DIR_MODE)
 			if err != nil {
 				return nil, err
 			}
+		} else if v.Type == "link" {
+			changed, err := EnsureSymlink(bp, file, v.Target)
+			if err != nil {
+				return nil, err
+			} else if changed {
+				m.bSettings.Audit.FileDownloaded(Downloadable{Path: file}, 0)
+			}
 		} else if v.Type == "file" {
+			previousHash := ""
 			_, err := os.Stat(file)
 			if !os.IsNotExist(err) {
 				sha1 := GetHashSha1(file)
@@ -151,21 +181,24 @@ func (m *JvmManager) ValidateInstallation() ([]Downloadable, error) {
 
 					// Just checking the executable flag
 					if v.Executable {
-						err := os.Chmod(file, os.ModePerm)
+						_, err := EnsureFileMode(file, EXECUTABLE_MODE)
 						if err != nil {
 							return nil, err
 						}
 					}
 					continue
 				}
+
+				previousHash = GetHash(file)
 			}
 
 			filesToDownload = append(filesToDownload, Downloadable{
-				Url:        v.Downloads.Raw.Url,
-				Path:       file,
-				Sha1:       v.Downloads.Raw.Hash,
-				Size:       v.Downloads.Raw.Size,
-				Executable: v.Executable,
+				Url:          v.Downloads.Raw.Url,
+				Path:         file,
+				Sha1:         v.Downloads.Raw.Hash,
+				Size:         v.Downloads.Raw.Size,
+				Executable:   v.Executable,
+				PreviousHash: previousHash,
 			})
 		}
 	}
@@ -182,6 +215,7 @@ func (m *JvmManager) ValidateInstallation() ([]Downloadable, error) {
 
 		if !slices.Contains(fileList, currPath) {
 			fmt.Printf("File / dir %v should not exist. Removing it.\n", currPath)
+			m.bSettings.Audit.FileRemoved(currPath)
 			if err := os.RemoveAll(currPath); err != nil {
 				return err
 			}
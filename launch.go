@@ -0,0 +1,355 @@
+/**
+ * Spectrum-Bootstrap - A bootstrap for Minecraft launchers
+ * Copyright (C) 2023-2024 - Oxodao
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ **/
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/jeandeaual/go-locale"
+)
+
+const (
+	LaunchKindJava       = "java"
+	LaunchKindExecutable = "executable"
+	LaunchKindPython     = "python"
+)
+
+var LaunchKinds = []string{LaunchKindJava, LaunchKindExecutable, LaunchKindPython}
+
+var (
+	ErrJavaRequired      = errors.New("java launchers need a java runtime")
+	ErrPythonRequired    = errors.New("python launchers need a python runtime")
+	ErrInvalidExecutable = errors.New("invalid executable")
+	ErrInvalidScript     = errors.New("invalid python script")
+)
+
+// Manifests made before the kinds existed are java launchers
+func (lm *LauncherManifest) GetKind() string {
+	if len(lm.Kind) == 0 {
+		return LaunchKindJava
+	}
+
+	return lm.Kind
+}
+
+func (m *LauncherManager) NeedsJava() bool {
+	return m.launcherManifest.GetKind() == LaunchKindJava
+}
+
+func (m *LauncherManager) NeedsPython() bool {
+	return m.launcherManifest.GetKind() == LaunchKindPython
+}
+
+// The executable of the manifest, it must be one of the launcher files
+func (m *LauncherManager) GetExecutablePath(variables map[string]string) (string, error) {
+	return m.getLauncherFile(m.launcherManifest.Executable, variables, ErrInvalidExecutable)
+}
+
+// The script of a python launcher, it must be one of the launcher files too
+func (m *LauncherManager) GetScriptPath(variables map[string]string) (string, error) {
+	return m.getLauncherFile(m.launcherManifest.Python.Script, variables, ErrInvalidScript)
+}
+
+func (m *LauncherManager) getLauncherFile(name string, variables map[string]string, errInvalid error) (string, error) {
+	name, err := ExpandTemplate(name, variables)
+	if err != nil {
+		return "", err
+	}
+
+	clean := path.Clean(strings.ReplaceAll(name, "\\", "/"))
+	if len(name) == 0 || path.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, "../") || strings.Contains(clean, ":") {
+		return "", fmt.Errorf("%w: %q must be relative to the launcher folder", errInvalid, name)
+	}
+
+	file := filepath.Join(m.GetPath(), filepath.FromSlash(clean))
+	if _, err := os.Stat(file); err != nil {
+		return "", fmt.Errorf("%w: %v", errInvalid, err)
+	}
+
+	return file, nil
+}
+
+// An argument is either a plain string or Mojang-style rules with its value(s):
+// { "rules": [...], "value": "-XstartOnFirstThread" }
+// { "rules": [...], "value": ["-Dfoo=bar", "-Dbar=baz"] }
+func (a *ConditionalArgument) UnmarshalJSON(data []byte) error {
+	var plain string
+	if err := json.Unmarshal(data, &plain); err == nil {
+		a.Rules = nil
+		a.Values = []string{plain}
+
+		return nil
+	}
+
+	var conditional struct {
+		Rules []Rule          `json:"rules"`
+		Value json.RawMessage `json:"value"`
+	}
+	if err := json.Unmarshal(data, &conditional); err != nil {
+		return err
+	}
+
+	a.Rules = conditional.Rules
+	if err := json.Unmarshal(conditional.Value, &plain); err == nil {
+		a.Values = []string{plain}
+		return nil
+	}
+
+	a.Values = []string{}
+
+	return json.Unmarshal(conditional.Value, &a.Values)
+}
+
+func (a ConditionalArgument) MarshalJSON() ([]byte, error) {
+	if len(a.Rules) == 0 && len(a.Values) == 1 {
+		return json.Marshal(a.Values[0])
+	}
+
+	return json.Marshal(struct {
+		Rules []Rule   `json:"rules,omitempty"`
+		Value []string `json:"value"`
+	}{a.Rules, a.Values})
+}
+
+// The memory settings of the player win over the manifest's defaults
+func GetMemorySettings(manifest *LauncherManifest, cfg *UserConfig) MemorySettings {
+	memory := manifest.Memory
+
+	if cfg.MinMemory > 0 {
+		memory.Min = cfg.MinMemory
+	}
+
+	if cfg.MaxMemory > 0 {
+		memory.Max = cfg.MaxMemory
+	}
+
+	if memory.Max > 0 && memory.Min > memory.Max {
+		fmt.Printf("Minimum memory (%vM) is higher than the maximum (%vM), using the maximum.\n", memory.Min, memory.Max)
+		memory.Min = memory.Max
+	}
+
+	return memory
+}
+
+// Arguments given to the JVM before the classpath and main class
+func (m *LauncherManager) GetJvmArguments(cfg *UserConfig, variables map[string]string) ([]string, error) {
+	args := []string{}
+
+	memory := GetMemorySettings(m.launcherManifest, cfg)
+	if memory.Min > 0 {
+		args = append(args, fmt.Sprintf("-Xms%vM", memory.Min))
+	}
+
+	if memory.Max > 0 {
+		args = append(args, fmt.Sprintf("-Xmx%vM", memory.Max))
+	}
+
+	env := GetRuleEnvironment(m.bSettings)
+	for _, arg := range m.launcherManifest.JvmArgs {
+		if !EvaluateRules(arg.Rules, env) {
+			continue
+		}
+
+		values, err := ExpandTemplates(arg.Values, variables)
+		if err != nil {
+			return nil, err
+		}
+
+		args = append(args, values...)
+	}
+
+	return args, nil
+}
+
+func GetJavaExecutable(platformOs, runtimePath string) (string, error) {
+	switch platformOs {
+	case "darwin":
+		return filepath.Join(runtimePath, "jre.bundle", "Contents", "Home", "bin", "java"), nil
+	case "linux":
+		return filepath.Join(runtimePath, "bin", "java"), nil
+	case "windows":
+		return filepath.Join(runtimePath, "bin", "javaw.exe"), nil
+	default:
+		return "", ErrFailedDetermineOs
+	}
+}
+
+// The values available to the placeholders of the manifest, i.e. ${rootPath}
+// The variables of the runtimes the launcher doesn't use are empty
+func GetLaunchVariables(bs *BootstrapSettings, lm *LauncherManager, runtimes *RuntimeManager, python *PythonManager) (map[string]string, error) {
+	variables, err := runtimes.GetVariables()
+	if err != nil {
+		return nil, err
+	}
+
+	pythonExecutable, pythonPath, pythonEnv := "", "", ""
+	if python != nil {
+		pythonExecutable = python.GetExecutable()
+		pythonPath = python.GetPath()
+		pythonEnv = python.GetEnvPath()
+	}
+
+	userLocale, err := locale.GetLocale()
+	if err != nil {
+		userLocale = ""
+	}
+
+	platform := bs.GetPlatform()
+
+	exeSuffix := ""
+	if platform.Os == "windows" {
+		exeSuffix = ".exe"
+	}
+
+	for name, value := range map[string]string{
+		"rootPath":         bs.LauncherPath,
+		"bsVersion":        BOOTSTRAP_VERSION,
+		"isPortable":       strconv.FormatBool(bs.Portable),
+		"channel":          bs.Channel,
+		"profile":          bs.Profile,
+		"brand":            bs.Brand,
+		"launcherPath":     lm.GetPath(),
+		"launcherVersion":  lm.launcherManifest.Version,
+		"pythonExecutable": pythonExecutable,
+		"pythonPath":       pythonPath,
+		"pythonEnv":        pythonEnv,
+		"os":               platform.Os,
+		"arch":             platform.Arch,
+		"libc":             platform.Libc,
+		"exeSuffix":        exeSuffix,
+		"locale":           userLocale,
+	} {
+		variables[name] = value
+	}
+
+	return variables, nil
+}
+
+// Builds the command starting the launcher
+func BuildLaunchCommand(bs *BootstrapSettings, cfg *UserConfig, lm *LauncherManager, runtimes *RuntimeManager, python *PythonManager) (*exec.Cmd, error) {
+	variables, err := GetLaunchVariables(bs, lm, runtimes, python)
+	if err != nil {
+		return nil, err
+	}
+
+	var executable string
+	args := []string{}
+	envDefaults := map[string]string{}
+
+	switch lm.launcherManifest.GetKind() {
+	case LaunchKindExecutable:
+		executable, err = lm.GetExecutablePath(variables)
+		if err != nil {
+			return nil, err
+		}
+
+	case LaunchKindPython:
+		if python == nil {
+			return nil, ErrPythonRequired
+		}
+
+		executable = variables["pythonExecutable"]
+		envDefaults = python.GetEnvironment()
+
+		// -s: the site-packages of the player must not be mixed with the environment
+		args = append(args, "-s")
+		if len(lm.launcherManifest.Python.Module) > 0 {
+			args = append(args, "-m", lm.launcherManifest.Python.Module)
+		} else {
+			script, err := lm.GetScriptPath(variables)
+			if err != nil {
+				return nil, err
+			}
+
+			args = append(args, script)
+		}
+
+	default:
+		if runtimes.GetMain() == nil {
+			return nil, ErrJavaRequired
+		}
+
+		executable = variables["javaExecutable"]
+
+		classpath := []string{}
+		for _, f := range lm.GetApplicableFiles() {
+			if f.Type == "classpath" {
+				classpath = append(classpath, filepath.Join(lm.GetPath(), f.Path))
+			}
+		}
+
+		args, err = lm.GetJvmArguments(cfg, variables)
+		if err != nil {
+			return nil, err
+		}
+
+		args = append(
+			args,
+			"-classpath",
+			strings.Join(classpath, string(os.PathListSeparator)),
+			lm.launcherManifest.MainClass,
+		)
+	}
+
+	launcherArgs, err := ExpandTemplates(lm.launcherManifest.Args, variables)
+	if err != nil {
+		return nil, err
+	}
+
+	env, err := lm.GetEnvironment(variables, envDefaults)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.Command(executable, append(args, launcherArgs...)...)
+	cmd.Dir = bs.LauncherPath
+	cmd.Env = env
+
+	return cmd, nil
+}
+
+// Saves the memory settings given on the command line
+// A negative value goes back to the manifest's default
+func (c *UserConfig) SetMemory(bs *BootstrapSettings, min, max int) error {
+	if min == 0 && max == 0 {
+		return nil
+	}
+
+	if min < 0 {
+		c.MinMemory = 0
+	} else if min > 0 {
+		c.MinMemory = min
+	}
+
+	if max < 0 {
+		c.MaxMemory = 0
+	} else if max > 0 {
+		c.MaxMemory = max
+	}
+
+	return c.Save(bs)
+}
@@ -25,11 +25,19 @@ import (
 	"path"
 	"path/filepath"
 	"slices"
+	"strconv"
+	"strings"
 )
 
+// How many fallback manifests we follow before giving up on staged rollouts
+const MAX_ROLLOUT_FALLBACKS = 5
+
 type LauncherManager struct {
 	launcherManifest *LauncherManifest
 	bSettings        *BootstrapSettings
+
+	// Archives to extract once downloaded
+	pendingArchives []ManifestFile
 }
 
 func GetLauncherManager(bs *BootstrapSettings) (*LauncherManager, error) {
@@ -38,64 +46,229 @@ func GetLauncherManager(bs *BootstrapSettings) (*LauncherManager, error) {
 	}
 
 	// We load the main manifest
-	mainManifest, err := GetOrCached[LauncherManifest](
-		bs,
-		filepath.Join(bs.LauncherPath, ".cache", "launcher_manifest.json"),
-		bs.ManifestURL,
-	)
+	cachePath := filepath.Join(bs.LauncherPath, ".cache", bs.GetLauncherManifestCacheName())
+	previousManifest, _ := LoadFromCache[LauncherManifest](cachePath)
+
+	mainManifest, err := GetOrCachedSigned[LauncherManifest](bs, cachePath, bs.ManifestURL)
+	if err != nil {
+		return nil, err
+	}
+
+	mainManifest = launcherManager.followMoves(cachePath, mainManifest, previousManifest)
+
+	launcherManager.launcherManifest, err = launcherManager.resolveRollout(mainManifest)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(bs.PinnedVersion) > 0 && bs.PinnedVersion != launcherManager.launcherManifest.Version {
+		pinnedManifest, err := LoadRetainedManifest(bs, bs.PinnedVersion)
+		if err != nil {
+			fmt.Printf("Launcher version %v is pinned but can't be used (%v), using %v.\n", bs.PinnedVersion, err, launcherManager.launcherManifest.Version)
+		} else {
+			launcherManager.launcherManifest = pinnedManifest
+		}
+	}
+
+	launcherManager.launcherManifest, err = ResolveIncludes(bs, launcherManager.launcherManifest)
 	if err != nil {
 		return nil, err
 	}
 
-	launcherManager.launcherManifest = mainManifest
+	bs.Audit.Manifest("launcher/"+bs.Channel, launcherManager.launcherManifest.Version)
 
 	return launcherManager, nil
 }
 
+// The manifest can tell that it moved, its new location is then used for the next runs
+// When it can't be reached, we stay with the manifest we have
+// previous is the manifest cached before this run, a move to an older one is not remembered
+func (m *LauncherManager) followMoves(cachePath string, manifest, previous *LauncherManifest) *LauncherManifest {
+	url := m.bSettings.GetRelocatedUrl(m.bSettings.ManifestURL)
+
+	for i := 0; i < MAX_RELOCATIONS && len(manifest.MovedTo) > 0 && manifest.MovedTo != url; i++ {
+		moved, err := DoGetRequest[LauncherManifest](m.bSettings, manifest.MovedTo, true)
+		if err != nil {
+			fmt.Printf("The launcher manifest moved to %v but it can't be loaded: %v\n", manifest.MovedTo, err)
+			return manifest
+		}
+
+		// Only a signed manifest can move us somewhere else for good
+		if len(m.bSettings.PublicKeys) > 0 {
+			if err := m.bSettings.SaveRelocation(m.bSettings.ManifestURL, manifest.MovedTo, moved, previous); err != nil {
+				fmt.Printf("Failed to remember that the launcher manifest moved to %v: %v\n", manifest.MovedTo, err)
+			}
+		}
+
+		if err := SaveToCache(cachePath, moved); err != nil {
+			fmt.Println("Failed to cache the launcher manifest:", err)
+		}
+
+		url = manifest.MovedTo
+		manifest = moved
+	}
+
+	return manifest
+}
+
+// Staged rollouts: installations that are not part of it yet
+// get the fallback manifest, or keep the version they have installed
+func (m *LauncherManager) resolveRollout(manifest *LauncherManifest) (*LauncherManifest, error) {
+	for i := 0; i < MAX_ROLLOUT_FALLBACKS; i++ {
+		if m.bSettings.Installation.IsInRollout(manifest.Rollout) {
+			return manifest, nil
+		}
+
+		if len(manifest.Rollout.FallbackManifest) == 0 {
+			versions, err := ListRetainedVersions(m.bSettings)
+			if err == nil && len(versions) > 0 {
+				installed, err := LoadRetainedManifest(m.bSettings, versions[0].Version)
+				if err == nil {
+					return installed, nil
+				}
+			}
+
+			// Nothing installed yet, better have the new release than nothing
+			return manifest, nil
+		}
+
+		fallback, err := GetOrCachedSigned[LauncherManifest](
+			m.bSettings,
+			filepath.Join(m.bSettings.LauncherPath, ".cache", "rollout_"+strconv.Itoa(i)+"_"+m.bSettings.GetLauncherManifestCacheName()),
+			manifest.Rollout.FallbackManifest,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		manifest = fallback
+	}
+
+	return manifest, nil
+}
+
 func (m *LauncherManager) GetPath() string {
 	return path.Join(m.bSettings.LauncherPath, "launcher")
 }
 
+// The files of the manifest whose rules apply to this computer
+func (m *LauncherManager) GetApplicableFiles() []ManifestFile {
+	env := GetRuleEnvironment(m.bSettings)
+
+	files := []ManifestFile{}
+	for _, f := range m.launcherManifest.Files {
+		if EvaluateRules(f.Rules, env) {
+			files = append(files, f)
+		}
+	}
+
+	return files
+}
+
 // Returns a list of files to re-download
 func (m *LauncherManager) ValidateInstallation() ([]Downloadable, error) {
 	bp := m.GetPath()
-	os.MkdirAll(bp, os.ModePerm)
+	os.MkdirAll(bp, DIR_MODE)
 
 	filesToDownload := []Downloadable{}
 	fileList := []string{}
 
-	for _, v := range m.launcherManifest.Files {
+	files, links := []string{}, []string{}
+	for _, v := range m.GetApplicableFiles() {
+		if v.Type == "link" {
+			links = append(links, filepath.Join(bp, v.Path))
+		} else {
+			files = append(files, filepath.Join(bp, v.Path))
+		}
+	}
+
+	if err := RemoveStaleLinks(bp, files, links); err != nil {
+		return nil, err
+	}
+
+	for _, v := range m.GetApplicableFiles() {
 		file := filepath.Join(bp, v.Path)
 		fileList = append(fileList, file)
 
 		if v.Type == "directory" {
-			err := os.MkdirAll(file, os.ModePerm)
+			err := os.MkdirAll(file, DIR_MODE)
+			if err != nil {
+				return nil, err
+			}
+
+			if len(v.Mode) > 0 {
+				if _, err := EnsureFileMode(file, v.GetMode()); err != nil {
+					return nil, err
+				}
+			}
+		} else if v.Type == "link" {
+			changed, err := EnsureSymlink(bp, file, v.Target)
 			if err != nil {
 				return nil, err
+			} else if changed {
+				m.bSettings.Audit.FileDownloaded(Downloadable{Path: file}, 0)
+			}
+		} else if v.Type == "archive" {
+			extracted, valid := m.checkExtractedArchive(v, file)
+			fileList = append(fileList, extracted...)
+			if valid {
+				continue
+			}
+
+			m.pendingArchives = append(m.pendingArchives, v)
+
+			archive := m.getArchivePath(v)
+			if GetHash(archive) == strings.ToLower(v.Hash) {
+				continue
 			}
+
+			filesToDownload = append(filesToDownload, Downloadable{
+				Url:    v.Url,
+				Path:   archive,
+				Sha256: v.Hash,
+				Size:   v.Size,
+			})
 		} else if v.Type == "file" || v.Type == "classpath" {
+			previousHash := ""
 			_, err := os.Stat(file)
 			if !os.IsNotExist(err) {
 				hash := GetHash(file)
-				if hash == v.Hash {
+				if strings.EqualFold(hash, v.Hash) {
 					// The file exists and has the correct hash
-					// No need to redownload
+					// No need to redownload, just repairing its permissions
+					changed, err := EnsureFileMode(file, v.GetMode())
+					if err != nil {
+						return nil, err
+					} else if changed {
+						fmt.Printf("Fixed the permissions of %v.\n", file)
+					}
+
+					continue
+				}
+
+				previousHash = hash
+			}
+
+			// No need to download a file we kept from a previous version
+			if retained := m.FindRetainedFile(v); len(retained) > 0 {
+				if err := CopyFile(retained, file); err == nil {
+					if _, err := EnsureFileMode(file, v.GetMode()); err != nil {
+						return nil, err
+					}
+
+					m.bSettings.Audit.FileDownloaded(Downloadable{Path: file, PreviousHash: previousHash}, 0)
 					continue
 				}
 			}
 
 			filesToDownload = append(filesToDownload, Downloadable{
-				Url:        v.Url,
-				Path:       file,
-				Sha256:     v.Hash,
-				Size:       v.Size,
-				Executable: false,
-				// @TODO Maybe later, but there should no need to have an executable
-				// Unless we want to support Java in other languages
-				// Like go which produces direct executables or python
-				// Maybe really later
-				// This could lead this bootstrap to be more generic
-				// instead of a Minecraft focused thing
+				Url:          v.Url,
+				Path:         file,
+				Sha256:       v.Hash,
+				Size:         v.Size,
+				PreviousHash: previousHash,
+				Executable:   v.Executable,
+				Mode:         v.GetMode(),
 			})
 		}
 	}
@@ -111,7 +284,13 @@ func (m *LauncherManager) ValidateInstallation() ([]Downloadable, error) {
 		}
 
 		if !slices.Contains(fileList, currPath) {
+			relPath, err := filepath.Rel(bp, currPath)
+			if err == nil && IsPreserved(m.launcherManifest.Preserve, relPath) {
+				return nil
+			}
+
 			fmt.Printf("File / dir %v should not exist. Removing it.\n", currPath)
+			m.bSettings.Audit.FileRemoved(currPath)
 			if err := os.RemoveAll(currPath); err != nil {
 				return err
 			}
@@ -0,0 +1,262 @@
+/**
+ * Spectrum-Bootstrap - A bootstrap for Minecraft launchers
+ * Copyright (C) 2023-2024 - Oxodao
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ **/
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+// Every installed launcher version is copied to $basepath/versions/{version}
+// with the manifest that describes it, so that we can go back to it
+// without needing the webserver to still serve the old files.
+const (
+	LAUNCHER_VERSIONS_DIR      = "versions"
+	LAUNCHER_VERSIONS_MANIFEST = "launcher_manifest.json"
+	LAUNCHER_VERSIONS_FILES    = "files"
+
+	DEFAULT_KEEP_VERSIONS = 3
+)
+
+var (
+	ErrVersionNotRetained = errors.New("this launcher version is not available locally")
+	ErrNoPreviousVersion  = errors.New("no previous launcher version to roll back to")
+)
+
+type RetainedVersion struct {
+	Version     string
+	Path        string
+	InstalledAt time.Time
+}
+
+func GetLauncherVersionsPath(bs *BootstrapSettings) string {
+	return filepath.Join(bs.LauncherPath, LAUNCHER_VERSIONS_DIR)
+}
+
+func GetLauncherVersionPath(bs *BootstrapSettings, version string) string {
+	// The version comes from the manifest, it should never be able to escape the folder
+	name := strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == os.PathSeparator {
+			return '_'
+		}
+
+		return r
+	}, version)
+
+	if name == "." || name == ".." {
+		name = "_" + name
+	}
+
+	return filepath.Join(GetLauncherVersionsPath(bs), name)
+}
+
+// Lists the launcher versions kept on disk, the most recently installed first
+func ListRetainedVersions(bs *BootstrapSettings) ([]RetainedVersion, error) {
+	entries, err := os.ReadDir(GetLauncherVersionsPath(bs))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	} else if err != nil {
+		return []RetainedVersion{}, nil
+	}
+
+	versions := []RetainedVersion{}
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+
+		path := filepath.Join(GetLauncherVersionsPath(bs), e.Name())
+		fi, err := os.Stat(filepath.Join(path, LAUNCHER_VERSIONS_MANIFEST))
+		if err != nil {
+			// Incomplete copy, we don't know what's in there
+			continue
+		}
+
+		manifest, err := LoadFromCache[LauncherManifest](filepath.Join(path, LAUNCHER_VERSIONS_MANIFEST))
+		if err != nil || manifest == nil {
+			continue
+		}
+
+		versions = append(versions, RetainedVersion{
+			Version:     manifest.Version,
+			Path:        path,
+			InstalledAt: fi.ModTime(),
+		})
+	}
+
+	sort.Slice(versions, func(i, j int) bool {
+		return versions[i].InstalledAt.After(versions[j].InstalledAt)
+	})
+
+	return versions, nil
+}
+
+func LoadRetainedManifest(bs *BootstrapSettings, version string) (*LauncherManifest, error) {
+	manifest, err := LoadFromCache[LauncherManifest](
+		filepath.Join(GetLauncherVersionPath(bs, version), LAUNCHER_VERSIONS_MANIFEST),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if manifest == nil || manifest.Version != version {
+		return nil, fmt.Errorf("%w: %v", ErrVersionNotRetained, version)
+	}
+
+	return manifest, nil
+}
+
+// Looks for a copy of the file in the retained versions
+// Returns an empty string if none matches the expected hash
+func (m *LauncherManager) FindRetainedFile(f ManifestFile) string {
+	versions, err := ListRetainedVersions(m.bSettings)
+	if err != nil {
+		return ""
+	}
+
+	for _, v := range versions {
+		file := filepath.Join(v.Path, LAUNCHER_VERSIONS_FILES, f.Path)
+		if GetHash(file) == f.Hash {
+			return file
+		}
+	}
+
+	return ""
+}
+
+// Keeps a copy of the launcher that was just installed
+// then removes the oldest versions to only keep the configured amount
+func (m *LauncherManager) RetainInstalledVersion() error {
+	bp := GetLauncherVersionPath(m.bSettings, m.launcherManifest.Version)
+	manifestPath := filepath.Join(bp, LAUNCHER_VERSIONS_MANIFEST)
+
+	existing, err := LoadRetainedManifest(m.bSettings, m.launcherManifest.Version)
+	if err != nil && !errors.Is(err, ErrVersionNotRetained) {
+		return err
+	}
+
+	if existing == nil {
+		// Removing a previous incomplete copy
+		if err := os.RemoveAll(bp); err != nil {
+			return err
+		}
+
+		for _, f := range m.GetApplicableFiles() {
+			if f.Type != "file" && f.Type != "classpath" {
+				continue
+			}
+
+			err := CopyFile(
+				filepath.Join(m.GetPath(), f.Path),
+				filepath.Join(bp, LAUNCHER_VERSIONS_FILES, f.Path),
+			)
+			if err != nil {
+				return err
+			}
+		}
+
+		// The manifest is written last, a version is only complete once it exists
+		if err := SaveToCache(manifestPath, m.launcherManifest); err != nil {
+			return err
+		}
+	} else {
+		// Already retained, we just mark it as the last installed one
+		now := time.Now()
+		if err := os.Chtimes(manifestPath, now, now); err != nil {
+			return err
+		}
+	}
+
+	if err := m.pruneRetainedVersions(); err != nil {
+		return err
+	}
+
+	return m.pruneArchives()
+}
+
+func (m *LauncherManager) pruneRetainedVersions() error {
+	keep := m.bSettings.KeepVersions
+	if keep <= 0 {
+		keep = DEFAULT_KEEP_VERSIONS
+	}
+
+	versions, err := ListRetainedVersions(m.bSettings)
+	if err != nil {
+		return err
+	}
+
+	for i, v := range versions {
+		if i < keep || v.Version == m.bSettings.PinnedVersion {
+			continue
+		}
+
+		fmt.Printf("Removing old launcher version %v.\n", v.Version)
+		if err := os.RemoveAll(v.Path); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func PinLauncherVersion(bs *BootstrapSettings, cfg *UserConfig, version string) error {
+	if _, err := LoadRetainedManifest(bs, version); err != nil {
+		return err
+	}
+
+	cfg.PinnedVersion = version
+	bs.PinnedVersion = version
+
+	return cfg.Save(bs)
+}
+
+// Pins the version that was installed before the current one
+func RollbackLauncherVersion(bs *BootstrapSettings, cfg *UserConfig) (string, error) {
+	versions, err := ListRetainedVersions(bs)
+	if err != nil {
+		return "", err
+	}
+
+	if len(versions) < 2 {
+		return "", ErrNoPreviousVersion
+	}
+
+	// The first one is the currently installed version. When versions are semver
+	// we don't want a second rollback to bring back the broken release.
+	current, err := semver.NewVersion(versions[0].Version)
+	for _, v := range versions[1:] {
+		if err == nil {
+			previous, err := semver.NewVersion(v.Version)
+			if err == nil && !previous.LessThan(current) {
+				continue
+			}
+		}
+
+		return v.Version, PinLauncherVersion(bs, cfg, v.Version)
+	}
+
+	return "", ErrNoPreviousVersion
+}
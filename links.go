@@ -0,0 +1,170 @@
+/**
+ * Spectrum-Bootstrap - A bootstrap for Minecraft launchers
+ * Copyright (C) 2023-2024 - Oxodao
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ **/
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+var ErrUnsafeLink = errors.New("unsafe link")
+
+// Link targets are relative to the folder of the link, like Mojang's runtimes do
+// They must stay in the root folder, otherwise a manifest could expose any file of the computer
+func CheckLinkTarget(root, link, target string) error {
+	target = filepath.FromSlash(target)
+	if len(target) == 0 || filepath.IsAbs(target) || len(filepath.VolumeName(target)) > 0 {
+		return fmt.Errorf("%w: %v must point to a relative path, got %q", ErrUnsafeLink, link, target)
+	}
+
+	// The system resolves up/.. through the up link, not as written, only the leading .. can be checked
+	named := false
+	for _, part := range strings.Split(filepath.ToSlash(target), "/") {
+		if part == ".." && named {
+			return fmt.Errorf("%w: %v can only go up at the start of its target, got %q", ErrUnsafeLink, link, target)
+		}
+
+		named = named || (part != ".." && part != "." && len(part) > 0)
+	}
+
+	if !isInRoot(root, filepath.Join(filepath.Dir(link), target)) {
+		return fmt.Errorf("%w: %v points outside of %v", ErrUnsafeLink, link, root)
+	}
+
+	return nil
+}
+
+func isInRoot(root, file string) bool {
+	rel, err := filepath.Rel(root, file)
+
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// The folders between the root and the file must not be links, the check on the target of a link only holds
+// when its folder is a real one: with a/up -> .. and a/up/esc -> .., a/up/esc/file would be outside of the root
+func CheckNoLinkInPath(root, file string) error {
+	link, err := findLinkInPath(root, file)
+	if err != nil {
+		return err
+	} else if len(link) > 0 {
+		return fmt.Errorf("%w: %v goes through the link %v", ErrUnsafeLink, file, link)
+	}
+
+	return nil
+}
+
+// The first folder between the root and the file that is a link, empty when there is none
+func findLinkInPath(root, file string) (string, error) {
+	if !isInRoot(root, file) {
+		return "", fmt.Errorf("%w: %v is outside of %v", ErrUnsafeLink, file, root)
+	}
+
+	rel, _ := filepath.Rel(root, filepath.Dir(file))
+	if rel == "." {
+		return "", nil
+	}
+
+	current := root
+	for _, part := range strings.Split(rel, string(filepath.Separator)) {
+		current = filepath.Join(current, part)
+
+		fi, err := os.Lstat(current)
+		if os.IsNotExist(err) {
+			return "", nil
+		} else if err != nil {
+			return "", err
+		}
+
+		if fi.Mode()&os.ModeSymlink != 0 {
+			return current, nil
+		}
+	}
+
+	return "", nil
+}
+
+// Removes the links found where files or folders are expected, i.e. left by a previous version, so that nothing
+// is written through them. The files going through one of the expected links are refused
+func RemoveStaleLinks(root string, files, links []string) error {
+	expected := map[string]bool{}
+	for _, link := range links {
+		expected[link] = true
+	}
+
+	// Parents first, the links in the way of the following files are already removed
+	paths := append(slices.Clone(files), links...)
+	slices.Sort(paths)
+
+	for _, file := range paths {
+		link, err := findLinkInPath(root, file)
+		if err != nil {
+			return err
+		} else if len(link) > 0 && expected[link] {
+			return fmt.Errorf("%w: %v goes through the link %v", ErrUnsafeLink, file, link)
+		}
+
+		if len(link) == 0 && !expected[file] {
+			if fi, err := os.Lstat(file); err == nil && fi.Mode()&os.ModeSymlink != 0 {
+				link = file
+			}
+		}
+
+		if len(link) > 0 {
+			fmt.Printf("%v should not be a link, removing it.\n", link)
+			if err := os.Remove(link); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Creates the link, or replaces whatever is at its path when it is not the expected link
+// Returns whether something was changed
+func EnsureSymlink(root, link, target string) (bool, error) {
+	if err := CheckLinkTarget(root, link, target); err != nil {
+		return false, err
+	} else if err := CheckNoLinkInPath(root, link); err != nil {
+		return false, err
+	}
+
+	target = filepath.FromSlash(target)
+
+	if current, err := os.Readlink(link); err == nil && current == target {
+		return false, nil
+	}
+
+	// A wrong link, or a file / folder that should be a link
+	if _, err := os.Lstat(link); err == nil {
+		if err := os.RemoveAll(link); err != nil {
+			return false, err
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(link), DIR_MODE); err != nil {
+		return false, err
+	}
+
+	return true, os.Symlink(target, link)
+}
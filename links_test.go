@@ -0,0 +1,150 @@
+/**
+ * Spectrum-Bootstrap - A bootstrap for Minecraft launchers
+ * Copyright (C) 2023-2024 - Oxodao
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ **/
+
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckLinkTarget(t *testing.T) {
+	root := filepath.Join(string(filepath.Separator), "root")
+
+	tests := []struct {
+		name   string
+		link   string
+		target string
+		err    error
+	}{
+		{"sibling", "bin/java", "javaw", nil},
+		{"parent folder", "lib/jli/libjli.so", "../libjli.so", nil},
+		{"up then down", "bin/java", "../lib/java", nil},
+		{"root", "a/up", "..", nil},
+		{"outside", "a/up", "../..", ErrUnsafeLink},
+		{"outside from the root", "up", "..", ErrUnsafeLink},
+		{"absolute", "bin/java", "/usr/bin/java", ErrUnsafeLink},
+		{"empty", "bin/java", "", ErrUnsafeLink},
+		{"up after a name", "a/l", "b/../x", ErrUnsafeLink},
+		{"up through a possible link", "l", "up/../../x", ErrUnsafeLink},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckLinkTarget(root, filepath.Join(root, filepath.FromSlash(tt.link)), tt.target)
+			if !errors.Is(err, tt.err) {
+				t.Errorf("expected the error %v, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestEnsureSymlinkChain(t *testing.T) {
+	tests := []struct {
+		name  string
+		links [][2]string
+		err   error
+	}{
+		{"link to a sibling", [][2]string{{"bin/java", "javaw"}}, nil},
+		{"links side by side", [][2]string{{"a/up", ".."}, {"b/up", ".."}}, nil},
+		// a/up is the root, a/up/esc would really be at root/esc and point above the root
+		{"link in a link", [][2]string{{"a/up", ".."}, {"a/up/esc", ".."}}, ErrUnsafeLink},
+		{"link in a link to a folder", [][2]string{{"lib", "a"}, {"lib/x", "y"}}, ErrUnsafeLink},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := filepath.Join(t.TempDir(), "root")
+
+			var err error
+			for _, l := range tt.links {
+				if _, err = EnsureSymlink(root, filepath.Join(root, filepath.FromSlash(l[0])), l[1]); err != nil {
+					break
+				}
+			}
+
+			if !errors.Is(err, tt.err) {
+				t.Errorf("expected the error %v, got %v", tt.err, err)
+			}
+
+			if _, err := os.Lstat(filepath.Join(filepath.Dir(root), "esc")); !os.IsNotExist(err) {
+				t.Errorf("a link was created outside of the root")
+			}
+		})
+	}
+}
+
+func TestRemoveStaleLinks(t *testing.T) {
+	tests := []struct {
+		name    string
+		links   [][2]string
+		files   []string
+		expects []string
+		err     error
+	}{
+		{"no links", nil, []string{"a/file"}, nil, nil},
+		{"link where a file is expected", [][2]string{{"a/file", "other"}}, []string{"a/file"}, nil, nil},
+		{"link where a folder is expected", [][2]string{{"a", "b"}}, []string{"a/file"}, nil, nil},
+		{"expected link kept", [][2]string{{"a/l", "file"}}, []string{"a/file"}, []string{"a/l"}, nil},
+		{"file in an expected link", [][2]string{{"a/up", ".."}}, []string{"a/up/file"}, []string{"a/up"}, ErrUnsafeLink},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := filepath.Join(t.TempDir(), "root")
+			for _, l := range tt.links {
+				if _, err := EnsureSymlink(root, filepath.Join(root, filepath.FromSlash(l[0])), l[1]); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			files, links := []string{}, []string{}
+			for _, f := range tt.files {
+				files = append(files, filepath.Join(root, filepath.FromSlash(f)))
+			}
+			for _, l := range tt.expects {
+				links = append(links, filepath.Join(root, filepath.FromSlash(l)))
+			}
+
+			err := RemoveStaleLinks(root, files, links)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("expected the error %v, got %v", tt.err, err)
+			} else if err != nil {
+				return
+			}
+
+			for _, f := range files {
+				if err := CheckNoLinkInPath(root, f); err != nil {
+					t.Errorf("expected no link in the path of %v, got %v", f, err)
+				}
+
+				if fi, err := os.Lstat(f); err == nil && fi.Mode()&os.ModeSymlink != 0 {
+					t.Errorf("expected %v not to be a link anymore", f)
+				}
+			}
+
+			for _, l := range links {
+				if fi, err := os.Lstat(l); err != nil || fi.Mode()&os.ModeSymlink == 0 {
+					t.Errorf("expected the link %v to be kept", l)
+				}
+			}
+		})
+	}
+}
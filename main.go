@@ -20,18 +20,14 @@
 
  import (
 	 _ "embed"
-	 "encoding/json"
-	 "flag"
+ 	 "flag"
 	 "fmt"
-	 "io"
-	 "net/http"
-	 "os"
-	 "os/exec"
-	 "path/filepath"
-	 "runtime"
-	 "strconv"
-	 "strings"
-	 "sync"
+ 	 "os"
+ 	 "path/filepath"
+ 	 "strconv"
+ 	 "sync"
+	 "sync/atomic"
+	 "time"
  
 	 "fyne.io/fyne/v2"
 	 "fyne.io/fyne/v2/app"
@@ -43,16 +39,37 @@
  var BOOTSTRAP_SETTINGS_STR []byte
  
  var basepath *string
+ var channel *string
+ var profileName *string
+ var minMemory *int
+ var maxMemory *int
+ var javaSource *string
+ var platformFlag *string
+ var manifestUrl *string
+ var brand *string
+ var folderName *string
+ var settingsOverrides *SettingsOverrides
  
  var BOOTSTRAP_VERSION = "1"
  
  func init() {
 	 basepath = flag.String("path", "", "The path to store launcher data (i.e. portable-mode)")
+	 channel = flag.String("channel", "", "The update channel to use (e.g. stable, beta), remembered for the next runs")
+	 profileName = flag.String("profile", "", "The launcher profile to start, when the bootstrap has several")
+	 minMemory = flag.Int("min-memory", 0, "The minimum memory of the launcher in megabytes, remembered for the next runs (-1 to reset)")
+	 maxMemory = flag.Int("max-memory", 0, "The maximum memory of the launcher in megabytes, remembered for the next runs (-1 to reset)")
+	 javaSource = flag.String("java", "", "Use an installed java: system to look for one, or the path of one (download to go back to the default), remembered for the next runs")
+	 platformFlag = flag.String("platform", "", "Install the runtimes of another platform instead of the detected one, i.e. linux/arm64 or linux/amd64/musl")
+	 manifestUrl = flag.String("manifest", "", "Override the launcher manifest url")
+	 brand = flag.String("brand", "", "Override the launcher brand")
+	 folderName = flag.String("folder-name", "", "Override the launcher folder name")
+ 
+	 settingsOverrides = &SettingsOverrides{}
+	 flag.Var(settingsOverrides, "setting", "Override a bootstrap setting, i.e. --setting launcher_keep_versions=5 (can be repeated)")
  }
  
  func main() {
-	 bsVersion, err := strconv.Atoi(BOOTSTRAP_VERSION)
-	 if err != nil {
+	 if _, err := strconv.Atoi(BOOTSTRAP_VERSION); err != nil {
 		 fmt.Println("Failed to parse bootstrap version to an int!")
 		 fmt.Println("Version found: ", BOOTSTRAP_VERSION)
  
@@ -61,6 +78,15 @@
  
 	 flag.Parse()
  
+	 if flag.NArg() > 0 {
+		 if err := RunCommand(flag.Args()); err != nil {
+			 fmt.Println(err)
+			 os.Exit(1)
+		 }
+ 
+		 os.Exit(0)
+	 }
+ 
 	 app := app.New()
 	 window := app.NewWindow("SpectrumBootstrap")
 	 window.SetFixedSize(true)
@@ -75,95 +101,114 @@
 		 window.CenterOnScreen()
  
 		 settings := BootstrapSettings{}
-		 err := json.Unmarshal(BOOTSTRAP_SETTINGS_STR, &settings)
-
- 
-		 if len(*basepath) > 0 {
-			 settings.LauncherPath = *basepath
-		 }
  
-		 settings.LauncherPath, err = GetLauncherDirectory(&settings)
-		 if err != nil {
+		 failInit := func(err error) {
+			 settings.Audit.Finish(err)
 			 window.SetContent(
 				 container.NewVBox(
 					 widget.NewLabel(Localize("failed_init", map[string]string{"Err": err.Error()})),
 				 ),
 			 )
 			 window.CenterOnScreen()
-			 return
 		 }
  
-		 window.SetTitle(settings.Brand + " - Bootstrap")
+		 // Waits for the player to click on one of the profiles
+		 chooseProfile := func(profiles []Profile) (string, error) {
+			 selected := make(chan string, 1)
+ 
+			 content := container.NewVBox(widget.NewLabel(Localize("choose_profile", nil)))
+			 for _, p := range profiles {
+				 id := p.ID
+				 content.Add(widget.NewButton(p.GetDisplayName(), func() {
+					 select {
+					 case selected <- id:
+					 default:
+					 }
+				 }))
+			 }
  
-		 launcherManager, err := GetLauncherManager(&settings)
-		 if err != nil {
-			 window.SetContent(
-				 container.NewVBox(
-					 widget.NewLabel(Localize("failed_init", map[string]string{"Err": err.Error()})),
-				 ),
-			 )
+			 window.SetContent(content)
 			 window.CenterOnScreen()
-			 return
-		 }
  
-		 jvmManager, err := GetJvmManager(&settings, launcherManager.launcherManifest.Java)
-		 if err != nil {
+			 id := <-selected
+ 
 			 window.SetContent(
 				 container.NewVBox(
-					 widget.NewLabel(Localize("failed_init", map[string]string{"Err": err.Error()})),
+					 widget.NewLabel(Localize("fetching_launcher_updates", nil)),
 				 ),
 			 )
 			 window.CenterOnScreen()
-			 return
+ 
+			 return id, nil
 		 }
-
-		 // Always attempt to get the legacy JVM manager
-		 jvmManagerLegacy, errLegacy := GetJvmManagerLegacy(&settings, launcherManager.launcherManifest.Java)
-		 if errLegacy != nil {
-			 window.SetContent(
-				 container.NewVBox(
-					 widget.NewLabel(Localize("failed_init", map[string]string{"Err": errLegacy.Error()})),
-				 ),
-			 )
-			 window.CenterOnScreen()
+ 
+		 userConfig, err := LoadBootstrapSettings(&settings, chooseProfile)
+		 if err != nil {
+			 failInit(err)
 			 return
 		 }
  
-		 jvmFilesToDownload, err := jvmManager.ValidateInstallation()
+		 settings.Audit, err = OpenAuditLog(&settings)
 		 if err != nil {
-			 window.SetContent(
-				 container.NewVBox(
-					 widget.NewLabel(Localize("failed_init", map[string]string{"Err": err.Error()})),
-				 ),
-			 )
-			 window.CenterOnScreen()
-			 return
+			 fmt.Println("Failed to open the audit log:")
+			 fmt.Println(err)
 		 }
 
-		  
-		 jvmFilesToDownloadLegacy, err := jvmManagerLegacy.ValidateInstallationLegacy()
+		 window.SetTitle(settings.Brand + " - Bootstrap")
+		 if len(settings.Icon) > 0 {
+			 window.SetIcon(fyne.NewStaticResource("icon.png", settings.Icon))
+		 }
+ 
+		 launcherManager, err := GetLauncherManager(&settings)
 		 if err != nil {
-			 window.SetContent(
-				 container.NewVBox(
-					 widget.NewLabel(Localize("failed_init", map[string]string{"Err": err.Error()})),
-				 ),
-			 )
-			 window.CenterOnScreen()
+			 failInit(err)
 			 return
 		 }
  
+		 // Executable launchers have no java runtime, only python launchers have a python one
+		 var runtimeManager *RuntimeManager
+		 var pythonManager *PythonManager
+		 filesToDownload := []Downloadable{}
+ 
+		 if launcherManager.NeedsJava() {
+			 runtimeManager, err = GetRuntimeManager(&settings, userConfig, launcherManager.launcherManifest.Java)
+			 if err != nil {
+				 failInit(err)
+				 return
+			 }
+ 
+			 jvmFilesToDownload, err := runtimeManager.ValidateInstallation()
+			 if err != nil {
+				 failInit(err)
+				 return
+			 }
+ 
+			 filesToDownload = append(filesToDownload, jvmFilesToDownload...)
+		 }
+ 
+		 if launcherManager.NeedsPython() {
+			 pythonManager, err = GetPythonManager(&settings, *launcherManager.launcherManifest.Python)
+			 if err != nil {
+				 failInit(err)
+				 return
+			 }
+ 
+			 pythonFilesToDownload, err := pythonManager.ValidateInstallation()
+			 if err != nil {
+				 failInit(err)
+				 return
+			 }
+ 
+			 filesToDownload = append(filesToDownload, pythonFilesToDownload...)
+		 }
+ 
 		 launcherFilesToDownload, err := launcherManager.ValidateInstallation()
 		 if err != nil {
-			 window.SetContent(
-				 container.NewVBox(
-					 widget.NewLabel(Localize("failed_init", map[string]string{"Err": err.Error()})),
-				 ),
-			 )
-			 window.CenterOnScreen()
+			 failInit(err)
 			 return
 		 }
  
-		 filesToDownload := append(append(jvmFilesToDownload, jvmFilesToDownloadLegacy...), launcherFilesToDownload...) 
+		 filesToDownload = append(filesToDownload, launcherFilesToDownload...)
 		 timeLabel := widget.NewLabel("00:00:00")
 		 mainProgressBar := widget.NewProgressBar()
 		 filenameLabel := widget.NewLabel("-")
@@ -182,6 +227,18 @@
  
 		 processedFiles := 0
  
+		 var downloadFailed atomic.Bool
+		 failDownload := func(err error) bool {
+			 if err == nil {
+				 return false
+			 }
+ 
+			 downloadFailed.Store(true)
+			 settings.Audit.Finish(err)
+ 
+			 return ShowError(window, "fail_download", err)
+		 }
+ 
 		 // @TODO Make this base on goroutine to download multiple files at once
 		 var wg sync.WaitGroup
 		 downloadedBytes := make([]int64, len(filesToDownload)) // To track downloaded bytes for each file
@@ -192,22 +249,12 @@
 			 go func(i int, f Downloadable) {
 				 defer wg.Done() // Decrement the counter when the goroutine completes
  
-				 err := os.MkdirAll(filepath.Dir(f.Path), os.ModePerm)
-				 if err != nil {
-					 window.SetContent(
-						 container.NewVBox(
-							 widget.NewLabel(Localize("fail_download", map[string]string{"Err": err.Error()})),
-						 ),
-					 )
-					 window.CenterOnScreen()
-					 return
-				 }
+				 start := time.Now()
  
-				 out, err := os.Create(f.Path)
-				 if ShowError(window, "fail_download", err) {
+				 err := os.MkdirAll(filepath.Dir(f.Path), DIR_MODE)
+				 if failDownload(err) {
 					 return
 				 }
-				 defer out.Close()
  
 				 done := make(chan int64)
 				 go func() {
@@ -222,31 +269,18 @@
 				 }()
  
 				 // @TODO: 3 Retries per file
-				 req, err := http.NewRequest("GET", f.Url, nil)
-				 if ShowError(window, "fail_download", err) {
+				 n, err := DownloadFile(&settings, f)
+				 if failDownload(err) {
 					 return
 				 }
  
-				 req.Header.Set("User-Agent", "SpectrumBootstrap/"+BOOTSTRAP_VERSION)
- 
-				 resp, err := http.DefaultClient.Do(req)
-				 if ShowError(window, "fail_download", err) {
+				 done <- n // Send the number of bytes downloaded
+				 _, err = EnsureFileMode(f.Path, f.GetMode())
+				 if failDownload(err) {
 					 return
 				 }
-				 defer resp.Body.Close()
  
-				 n, err := io.Copy(out, resp.Body)
-				 if ShowError(window, "fail_download", err) {
-					 return
-				 }
- 
-				 done <- n // Send the number of bytes downloaded
-				 if f.Executable {
-					 err := os.Chmod(f.Path, os.ModePerm)
-					 if ShowError(window, "fail_download", err) {
-						 return
-					 }
-				 }
+				 settings.Audit.FileDownloaded(f, time.Since(start))
  
 				 processedFiles += 1
 				 mainProgressBar.SetValue(float64(processedFiles) / float64(len(filesToDownload)))
@@ -255,73 +289,67 @@
  
 		 wg.Wait() // Wait for all downloads to complete
  
-		 // Launching the launcher
-		 executablePath := ""
-		 classpathSeparator := ":"
-		 if runtime.GOOS == "darwin" {
-			 executablePath = "jre.bundle/Contents/Home/bin/java"
-		 } else if runtime.GOOS == "linux" {
-			 executablePath = "bin/java"
-		 } else if runtime.GOOS == "windows" {
-			 executablePath = "bin/javaw.exe"
-			 classpathSeparator = ";"
-		 } else {
-			 panic("How did we get here?")
+		 if downloadFailed.Load() {
+			 return
 		 }
  
-		 classpath := []string{}
-		 for _, f := range launcherManager.launcherManifest.Files {
-			 if f.Type == "classpath" {
-				 classpath = append(classpath, filepath.Join(settings.LauncherPath, "launcher", f.Path))
-			 }
+		 if err := launcherManager.ExtractPendingArchives(); err != nil {
+			 failInit(err)
+			 return
 		 }
  
-		 variables := map[string]any{
-			 "osArch":     jvmManager.os,
-			 "rootPath":   settings.LauncherPath,
-			 "bsVersion":  bsVersion,
-			 "isPortable": len(*basepath) > 0,
-		 }
+		 if runtimeManager != nil {
+			 if err := runtimeManager.InstallPending(); err != nil {
+				 failInit(err)
+				 return
+			 }
  
-		 cmdStrArr := []string{
-			 "-classpath",
-			 strings.Join(classpath, classpathSeparator),
-			 launcherManager.launcherManifest.MainClass,
+			 if err := runtimeManager.SaveInstalled(); err != nil {
+				 fmt.Println("Failed to record the installed runtimes:")
+				 fmt.Println(err)
+			 }
 		 }
  
-		 for _, arg := range launcherManager.launcherManifest.Args {
-			 val := arg
- 
-			 for k, v := range variables {
-				 val = strings.ReplaceAll(val, "${"+k+"}", fmt.Sprintf("%v", v))
+		 if pythonManager != nil {
+			 if err := pythonManager.InstallPending(); err != nil {
+				 failInit(err)
+				 return
 			 }
+		 }
  
-			 cmdStrArr = append(cmdStrArr, val)
+		 if err := launcherManager.RetainInstalledVersion(); err != nil {
+			 fmt.Println("Failed to keep a copy of the launcher:")
+			 fmt.Println(err)
 		 }
  
-		 cmd := exec.Command(
-			 filepath.Join(jvmManager.GetPath(), executablePath),
-			 cmdStrArr...,
-		 )
+		 // Launching the launcher
+		 cmd, err := BuildLaunchCommand(&settings, userConfig, launcherManager, runtimeManager, pythonManager)
+		 if err != nil {
+			 failInit(err)
+			 return
+		 }
  
 		 cmd.Stderr = os.Stderr
 		 cmd.Stdout = os.Stdout
-		 cmd.Dir = settings.LauncherPath
- 
+  
 		 if err = cmd.Start(); err != nil {
 			 fmt.Println("Failed to run the launcher:")
 			 fmt.Println(err)
+			 settings.Audit.Finish(err)
 			 os.Exit(1)
 		 }
  
+		 settings.Audit.LauncherStarted(cmd.Path)
 		 window.Hide()
  
 		 if err = cmd.Wait(); err != nil {
 			 fmt.Println("Failed to run the launcher:")
 			 fmt.Println(err)
+			 settings.Audit.Finish(err)
 			 os.Exit(1)
 		 }
  
+		 settings.Audit.Finish(nil)
 		 os.Exit(0)
 	 }()
  
@@ -0,0 +1,170 @@
+/**
+ * Spectrum-Bootstrap - A bootstrap for Minecraft launchers
+ * Copyright (C) 2023-2024 - Oxodao
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ **/
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Modules can include other modules, up to this depth
+const MAX_INCLUDE_DEPTH = 8
+
+var (
+	ErrIncludeCycle     = errors.New("modules include each other")
+	ErrIncludeTooDeep   = errors.New("modules are nested too deeply")
+	ErrManifestConflict = errors.New("conflicting manifest files")
+)
+
+// Where a file of the merged manifest comes from, for the conflict errors
+type mergedFile struct {
+	file   ManifestFile
+	source string
+}
+
+type manifestMerger struct {
+	bs    *BootstrapSettings
+	env   RuleEnvironment
+	files map[string]mergedFile
+	// Hashes of the modules being included, to detect cycles
+	including []string
+	// Hashes of the modules already merged, a module included twice is only merged once
+	merged map[string]bool
+	result *LauncherManifest
+}
+
+func getManifestFileKey(p string) string {
+	return strings.ToLower(path.Clean(strings.ReplaceAll(p, "\\", "/")))
+}
+
+// Two files conflict when they are written at the same path with different contents
+// Files that don't apply to this computer are never written, so they can't conflict
+func (m *manifestMerger) addFile(f ManifestFile, source string) error {
+	if !EvaluateRules(f.Rules, m.env) {
+		m.result.Files = append(m.result.Files, f)
+		return nil
+	}
+
+	key := getManifestFileKey(f.Path)
+	if existing, ok := m.files[key]; ok {
+		if existing.file.Type == f.Type && strings.EqualFold(existing.file.Hash, f.Hash) && existing.file.Target == f.Target {
+			return nil
+		}
+
+		return fmt.Errorf("%w: %v is set by %v and %v", ErrManifestConflict, f.Path, existing.source, source)
+	}
+
+	m.files[key] = mergedFile{file: f, source: source}
+	m.result.Files = append(m.result.Files, f)
+
+	return nil
+}
+
+func (m *manifestMerger) include(inc ManifestInclude, depth int) error {
+	hash := strings.ToLower(inc.Hash)
+
+	if m.merged[hash] {
+		return nil
+	}
+
+	for _, h := range m.including {
+		if h == hash {
+			return fmt.Errorf("%w: %v", ErrIncludeCycle, inc.Url)
+		}
+	}
+
+	if depth > MAX_INCLUDE_DEPTH {
+		return fmt.Errorf("%w: %v", ErrIncludeTooDeep, inc.Url)
+	}
+
+	// Modules are shared between the profiles, like the runtimes
+	module, err := GetOrCachedVerified[ManifestModule](
+		m.bs,
+		filepath.Join(m.bs.RuntimePath, ".cache", "modules", hash+".json"),
+		inc.Url,
+		hash,
+	)
+	if err != nil {
+		return err
+	}
+
+	source := "module " + inc.Url
+	if len(module.Name) > 0 {
+		source = "module " + module.Name
+	}
+
+	m.including = append(m.including, hash)
+	for _, sub := range module.Includes {
+		if err := m.include(sub, depth+1); err != nil {
+			return err
+		}
+	}
+	m.including = m.including[:len(m.including)-1]
+
+	for _, f := range module.Files {
+		if err := m.addFile(f, source); err != nil {
+			return err
+		}
+	}
+
+	m.result.Preserve = append(m.result.Preserve, module.Preserve...)
+	m.result.JvmArgs = append(m.result.JvmArgs, module.JvmArgs...)
+	m.merged[hash] = true
+
+	return nil
+}
+
+// Fetches the modules included by the manifest and returns a copy of it with their content merged
+// The result has no includes left, so that it can be kept as is with the retained versions
+func ResolveIncludes(bs *BootstrapSettings, lm *LauncherManifest) (*LauncherManifest, error) {
+	if len(lm.Includes) == 0 {
+		return lm, nil
+	}
+
+	result := *lm
+	result.Files = []ManifestFile{}
+	result.Preserve = append([]string{}, lm.Preserve...)
+	result.JvmArgs = append([]ConditionalArgument{}, lm.JvmArgs...)
+	result.Includes = nil
+
+	merger := &manifestMerger{
+		bs:     bs,
+		env:    GetRuleEnvironment(bs),
+		files:  map[string]mergedFile{},
+		merged: map[string]bool{},
+		result: &result,
+	}
+
+	for _, f := range lm.Files {
+		if err := merger.addFile(f, "the launcher manifest"); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, inc := range lm.Includes {
+		if err := merger.include(inc, 1); err != nil {
+			return nil, err
+		}
+	}
+
+	return &result, nil
+}
@@ -0,0 +1,722 @@
+/**
+ * Spectrum-Bootstrap - A bootstrap for Minecraft launchers
+ * Copyright (C) 2023-2024 - Oxodao
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ **/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// The schema of the launcher manifest this bootstrap is written for
+// Older manifests are migrated when loaded, see manifestMigrations
+const MANIFEST_SCHEMA_VERSION = 2
+
+var ErrInvalidManifest = errors.New("invalid launcher manifest")
+
+// The versions and components name the folders of the runtimes, they can't point outside of them
+var runtimeFolderRegex = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._-]*$`)
+
+// The names end up in the placeholders, i.e. ${legacyJavaExecutable}
+var runtimeNameRegex = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_]*$`)
+
+var ManifestFileTypes = []string{"file", "directory", "classpath", "link", "archive"}
+
+// Each migration takes a manifest of the schema version it is indexed at
+// and modifies it to match the next one
+// A version without migration only added new keys
+var manifestMigrations = map[int]func(manifest map[string]any) error{
+	1: migrateManifestV1,
+}
+
+// v1 => v2: jre.component and jre.componentLegacy became a list of runtimes
+// jre.component_legacy is read too, it was used by the bootstraps built before the runtimes
+func migrateManifestV1(manifest map[string]any) error {
+	jre, ok := manifest["jre"].(map[string]any)
+	if !ok {
+		return nil
+	}
+
+	if value, ok := jre["componentLegacy"]; ok {
+		if _, exists := jre["component_legacy"]; !exists {
+			jre["component_legacy"] = value
+		}
+		delete(jre, "componentLegacy")
+	}
+
+	runtimes := []any{}
+	for _, r := range []struct{ key, name string }{{"component", "main"}, {"component_legacy", "legacy"}} {
+		value, ok := jre[r.key]
+		if !ok {
+			continue
+		}
+		delete(jre, r.key)
+
+		// Unused components were left empty
+		if component, isString := value.(string); value == nil || (isString && len(component) == 0) {
+			continue
+		}
+
+		runtimes = append(runtimes, map[string]any{"name": r.name, "component": value})
+	}
+
+	if _, exists := jre["runtimes"]; !exists {
+		jre["runtimes"] = runtimes
+	}
+
+	return nil
+}
+
+// A single issue in the manifest, i.e. files[3].hash: expected a string, got 42
+type ManifestFieldError struct {
+	Path    string
+	Value   any
+	Message string
+}
+
+func (e ManifestFieldError) String() string {
+	location := e.Path
+	if len(location) == 0 {
+		location = "(root)"
+	}
+
+	if e.Value == nil {
+		return location + ": " + e.Message
+	}
+
+	value, _ := json.Marshal(e.Value)
+	if len(value) > 60 {
+		value = append(value[:57], "..."...)
+	}
+
+	return fmt.Sprintf("%v: %v, got %s", location, e.Message, value)
+}
+
+type ManifestValidationError struct {
+	Errors []ManifestFieldError
+}
+
+func (e *ManifestValidationError) Error() string {
+	lines := []string{ErrInvalidManifest.Error() + ":"}
+	for _, fe := range e.Errors {
+		lines = append(lines, "  - "+fe.String())
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func (e *ManifestValidationError) Unwrap() error {
+	return ErrInvalidManifest
+}
+
+// Checks a decoded json document against the go type it will be unmarshalled in
+// In strict mode, the unknown keys are errors, otherwise they are only reported as warnings
+type manifestValidator struct {
+	strict   bool
+	errors   []ManifestFieldError
+	warnings []ManifestFieldError
+}
+
+func (v *manifestValidator) fail(path string, value any, format string, args ...any) {
+	v.errors = append(v.errors, ManifestFieldError{Path: path, Value: value, Message: fmt.Sprintf(format, args...)})
+}
+
+func joinManifestPath(parent, key string) string {
+	if len(parent) == 0 {
+		return key
+	}
+
+	return parent + "." + key
+}
+
+// The json keys of a struct and the type of their field
+func getJsonFields(t reflect.Type) map[string]reflect.Type {
+	fields := map[string]reflect.Type{}
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+
+		if len(name) == 0 {
+			name = f.Name
+		}
+
+		fields[name] = f.Type
+	}
+
+	return fields
+}
+
+func getSortedKeys(obj map[string]any) []string {
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+func (v *manifestValidator) check(path string, value any, t reflect.Type) {
+	// null leaves the field to its zero value, as encoding/json does
+	if value == nil {
+		return
+	}
+
+	if t == reflect.TypeOf(ConditionalArgument{}) {
+		v.checkConditionalArgument(path, value)
+		return
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		v.check(path, value, t.Elem())
+
+	case reflect.Struct:
+		obj, ok := value.(map[string]any)
+		if !ok {
+			v.fail(path, value, "expected an object")
+			return
+		}
+
+		fields := getJsonFields(t)
+		for _, k := range getSortedKeys(obj) {
+			ft, ok := fields[k]
+			if !ok {
+				fe := ManifestFieldError{Path: joinManifestPath(path, k), Message: "unknown key"}
+				if v.strict {
+					v.errors = append(v.errors, fe)
+				} else {
+					v.warnings = append(v.warnings, fe)
+				}
+				continue
+			}
+
+			v.check(joinManifestPath(path, k), obj[k], ft)
+		}
+
+	case reflect.Map:
+		obj, ok := value.(map[string]any)
+		if !ok {
+			v.fail(path, value, "expected an object")
+			return
+		}
+
+		for _, k := range getSortedKeys(obj) {
+			v.check(joinManifestPath(path, k), obj[k], t.Elem())
+		}
+
+	case reflect.Slice:
+		arr, ok := value.([]any)
+		if !ok {
+			v.fail(path, value, "expected an array")
+			return
+		}
+
+		for i, elem := range arr {
+			v.check(fmt.Sprintf("%v[%d]", path, i), elem, t.Elem())
+		}
+
+	case reflect.String:
+		if _, ok := value.(string); !ok {
+			v.fail(path, value, "expected a string")
+		}
+
+	case reflect.Bool:
+		if _, ok := value.(bool); !ok {
+			v.fail(path, value, "expected a boolean")
+		}
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, ok := value.(json.Number)
+		if !ok {
+			v.fail(path, value, "expected an integer")
+		} else if _, err := n.Int64(); err != nil {
+			v.fail(path, value, "expected an integer")
+		}
+
+	case reflect.Float32, reflect.Float64:
+		if _, ok := value.(json.Number); !ok {
+			v.fail(path, value, "expected a number")
+		}
+	}
+}
+
+// Either a plain string or {"rules": [...], "value": "..." | ["...", ...]}
+func (v *manifestValidator) checkConditionalArgument(path string, value any) {
+	if _, ok := value.(string); ok {
+		return
+	}
+
+	obj, ok := value.(map[string]any)
+	if !ok {
+		v.fail(path, value, "expected a string or an object with rules and value")
+		return
+	}
+
+	v.check(path, map[string]any{"rules": obj["rules"]}, reflect.TypeOf(struct {
+		Rules []Rule `json:"rules"`
+	}{}))
+
+	for _, k := range getSortedKeys(obj) {
+		if k != "rules" && k != "value" {
+			v.fail(joinManifestPath(path, k), nil, "unknown key")
+		}
+	}
+
+	switch val := obj["value"].(type) {
+	case string:
+	case []any:
+		v.check(joinManifestPath(path, "value"), val, reflect.TypeOf([]string{}))
+	default:
+		v.fail(joinManifestPath(path, "value"), val, "expected a string or an array of strings")
+	}
+}
+
+// Checks what the types can't tell
+func (v *manifestValidator) checkLauncherManifest(lm *LauncherManifest) {
+	if len(lm.Version) == 0 {
+		v.fail("version", nil, "required")
+	}
+
+	// The manifest left at the old url only tells where the new one is, see followMoves
+	if len(lm.MovedTo) > 0 {
+		if !slices.Contains(LaunchKinds, lm.GetKind()) {
+			v.fail("kind", lm.Kind, "expected one of %v", strings.Join(LaunchKinds, ", "))
+		}
+	} else {
+		v.checkKind(lm)
+	}
+
+	if len(lm.Location) > 0 && !isHttpUrl(lm.Location) {
+		v.fail("location", lm.Location, "expected the url the manifest is published at")
+	}
+
+	if len(lm.MovedTo) > 0 && !isHttpUrl(lm.MovedTo) {
+		v.fail("moved_to", lm.MovedTo, "expected the url of the new manifest")
+	}
+
+	if lm.Rollout != nil && (lm.Rollout.Progress < 0 || lm.Rollout.Progress > 100) {
+		v.fail("rollout.progress", lm.Rollout.Progress, "expected a percentage between 0 and 100")
+	}
+
+	v.checkFiles(lm.Files)
+	v.checkJvmArgs(lm.JvmArgs)
+	v.checkIncludes(lm.Includes)
+}
+
+// What each kind of launcher needs to start
+func (v *manifestValidator) checkKind(lm *LauncherManifest) {
+	switch lm.GetKind() {
+	case LaunchKindJava:
+		if len(lm.MainClass) == 0 {
+			v.fail("main_class", nil, "required")
+		}
+
+		if len(lm.Executable) > 0 {
+			v.fail("executable", lm.Executable, "only for executable launchers")
+		}
+
+		if lm.Python != nil {
+			v.fail("python", nil, "only for python launchers")
+		}
+
+		v.checkRuntimes(lm.Java)
+
+	case LaunchKindExecutable:
+		if len(lm.Executable) == 0 {
+			v.fail("executable", nil, "required")
+		}
+
+		// The runtime is never downloaded for them, better tell it than ignoring it
+		if len(lm.MainClass) > 0 {
+			v.fail("main_class", lm.MainClass, "only for java launchers")
+		}
+
+		if len(lm.JvmArgs) > 0 || lm.Memory.Min > 0 || lm.Memory.Max > 0 {
+			v.fail("jvm_args", nil, "jvm_args and memory are only for java launchers")
+		}
+
+		if len(lm.Java.Runtimes) > 0 {
+			v.fail("jre", nil, "only for java launchers")
+		}
+
+		if lm.Python != nil {
+			v.fail("python", nil, "only for python launchers")
+		}
+
+	case LaunchKindPython:
+		if lm.Python == nil {
+			v.fail("python", nil, "required")
+		} else {
+			v.checkPython(lm.Python)
+		}
+
+		if len(lm.MainClass) > 0 {
+			v.fail("main_class", lm.MainClass, "only for java launchers")
+		}
+
+		if len(lm.Executable) > 0 {
+			v.fail("executable", lm.Executable, "only for executable launchers")
+		}
+
+		if len(lm.JvmArgs) > 0 || lm.Memory.Min > 0 || lm.Memory.Max > 0 {
+			v.fail("jvm_args", nil, "jvm_args and memory are only for java launchers")
+		}
+
+		if len(lm.Java.Runtimes) > 0 {
+			v.fail("jre", nil, "only for java launchers")
+		}
+
+	default:
+		v.fail("kind", lm.Kind, "expected one of %v", strings.Join(LaunchKinds, ", "))
+	}
+}
+
+func isHttpUrl(value string) bool {
+	u, err := url.Parse(value)
+
+	return err == nil && (u.Scheme == "https" || u.Scheme == "http") && len(u.Host) > 0
+}
+
+func (v *manifestValidator) checkManifestModule(mm *ManifestModule) {
+	v.checkFiles(mm.Files)
+	v.checkJvmArgs(mm.JvmArgs)
+	v.checkIncludes(mm.Includes)
+}
+
+func (v *manifestValidator) checkFiles(files []ManifestFile) {
+	for i, f := range files {
+		p := fmt.Sprintf("files[%d]", i)
+
+		if !slices.Contains(ManifestFileTypes, f.Type) {
+			v.fail(p+".type", f.Type, "expected one of %v", strings.Join(ManifestFileTypes, ", "))
+		}
+
+		// The files must stay in the launcher folder
+		clean := path.Clean(strings.ReplaceAll(f.Path, "\\", "/"))
+		if len(f.Path) == 0 {
+			v.fail(p+".path", nil, "required")
+		} else if path.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, "../") || strings.Contains(f.Path, ":") {
+			v.fail(p+".path", f.Path, "expected a path relative to the launcher folder")
+		}
+
+		if len(f.Mode) > 0 {
+			if _, err := ParseFileMode(f.Mode); err != nil {
+				v.fail(p+".mode", f.Mode, "expected unix permissions in octal, i.e. 0755")
+			}
+		}
+
+		if f.Type == "link" {
+			// The launcher folder is not known yet, checking the link as if it was at its root
+			root := string(filepath.Separator) + "root"
+			if len(f.Target) == 0 {
+				v.fail(p+".target", nil, "required")
+			} else if err := CheckLinkTarget(root, filepath.Join(root, filepath.FromSlash(f.Path)), f.Target); err != nil {
+				v.fail(p+".target", f.Target, "expected a path relative to the link that stays in the launcher folder")
+			}
+		} else if len(f.Target) > 0 {
+			v.fail(p+".target", f.Target, "only allowed for links")
+		}
+
+		if f.Type == "archive" && !slices.Contains(ArchiveFormats, f.GetArchiveFormat()) {
+			v.fail(p+".format", f.Format, "expected one of %v, it can only be omitted when the url ends with the extension", strings.Join(ArchiveFormats, ", "))
+		} else if f.Type != "archive" && len(f.Format) > 0 {
+			v.fail(p+".format", f.Format, "only allowed for archives")
+		}
+
+		if f.Type != "directory" && f.Type != "link" {
+			if len(f.Hash) == 0 {
+				v.fail(p+".hash", nil, "required")
+			} else if f.Type == "archive" && !IsSha256(f.Hash) {
+				v.fail(p+".hash", f.Hash, "expected the sha256 of the archive")
+			}
+
+			if len(f.Url) == 0 {
+				v.fail(p+".url", nil, "required")
+			}
+		}
+
+		for j, r := range f.Rules {
+			v.checkRule(fmt.Sprintf("%v.rules[%d]", p, j), r)
+		}
+	}
+}
+
+func (v *manifestValidator) checkJvmArgs(args []ConditionalArgument) {
+	for i, arg := range args {
+		for j, r := range arg.Rules {
+			v.checkRule(fmt.Sprintf("jvm_args[%d].rules[%d]", i, j), r)
+		}
+	}
+}
+
+func (v *manifestValidator) checkIncludes(includes []ManifestInclude) {
+	for i, inc := range includes {
+		p := fmt.Sprintf("includes[%d]", i)
+
+		if len(inc.Url) == 0 {
+			v.fail(p+".url", nil, "required")
+		}
+
+		if !IsSha256(inc.Hash) {
+			v.fail(p+".hash", inc.Hash, "expected the sha256 of the module")
+		}
+	}
+}
+
+func (v *manifestValidator) checkRuntimes(java LauncherJavaManifest) {
+	if len(java.Runtimes) == 0 {
+		v.fail("jre.runtimes", nil, "at least one runtime is required")
+	} else if java.Runtimes[0].Optional {
+		v.fail("jre.runtimes[0].optional", true, "the first runtime starts the launcher, it can't be optional")
+	}
+
+	names := map[string]bool{}
+	// The runtimes of a component share its folder, they can't install different builds
+	components := map[string]LauncherRuntime{}
+	needsManifest := false
+	for i, r := range java.Runtimes {
+		p := fmt.Sprintf("jre.runtimes[%d]", i)
+
+		if !runtimeNameRegex.MatchString(r.Name) {
+			v.fail(p+".name", r.Name, "expected letters, digits and underscores, starting with a letter")
+		} else if names[r.Name] {
+			v.fail(p+".name", r.Name, "already used by another runtime")
+		}
+		names[r.Name] = true
+
+		if len(r.Component) == 0 {
+			v.fail(p+".component", nil, "required")
+		} else if !runtimeFolderRegex.MatchString(r.Component) {
+			v.fail(p+".component", r.Component, "expected letters, digits, dots, dashes and underscores")
+		} else if other, ok := components[r.Component]; !ok {
+			components[r.Component] = r
+		} else if other.Version != r.Version {
+			v.fail(p+".version", r.Version, "another runtime uses %v with the version %q", r.Component, other.Version)
+		} else if other.GetSource() != r.GetSource() || !reflect.DeepEqual(other.Archives, r.Archives) {
+			v.fail(p+".source", r.Source, "another runtime installs %v from somewhere else", r.Component)
+		}
+
+		switch r.GetSource() {
+		case RuntimeSourceManifest:
+			needsManifest = true
+
+			if len(r.Archives) > 0 {
+				v.fail(p+".archives", nil, "only for the archive source")
+			}
+
+		case RuntimeSourceArchive:
+			if len(r.Archives) == 0 {
+				v.fail(p+".archives", nil, "at least one archive is required")
+			}
+
+			for j, a := range r.Archives {
+				ap := fmt.Sprintf("%v.archives[%d]", p, j)
+
+				if len(a.Url) == 0 {
+					v.fail(ap+".url", nil, "required")
+				}
+
+				if !IsSha256(a.Hash) {
+					v.fail(ap+".hash", a.Hash, "expected the sha256 of the archive")
+				}
+
+				if !slices.Contains(ArchiveFormats, GetArchiveFormat(a.Format, a.Url)) {
+					v.fail(ap+".format", a.Format, "expected one of %v, it can only be omitted when the url ends with the extension", strings.Join(ArchiveFormats, ", "))
+				}
+
+				for k, rule := range a.Rules {
+					v.checkRule(fmt.Sprintf("%v.rules[%d]", ap, k), rule)
+				}
+			}
+
+		default:
+			v.fail(p+".source", r.Source, "expected one of %v", strings.Join(RuntimeSources, ", "))
+		}
+	}
+
+	if needsManifest && len(java.ManifestURL) == 0 {
+		v.fail("jre.manifest", nil, "required, unless all the runtimes come from archives")
+	}
+}
+
+func (v *manifestValidator) checkPython(p *LauncherPythonManifest) {
+	if len(p.ManifestURL) == 0 {
+		v.fail("python.manifest", nil, "required")
+	}
+
+	if len(p.Version) == 0 {
+		v.fail("python.version", nil, "required")
+	} else if !runtimeFolderRegex.MatchString(p.Version) {
+		v.fail("python.version", p.Version, "expected letters, digits, dots, dashes and underscores")
+	}
+
+	if len(p.Module) == 0 && len(p.Script) == 0 {
+		v.fail("python.module", nil, "either module or script is required")
+	} else if len(p.Module) > 0 && len(p.Script) > 0 {
+		v.fail("python.script", p.Script, "module and script can't be used together")
+	}
+
+	for i, w := range p.Wheels {
+		wp := fmt.Sprintf("python.wheels[%d]", i)
+
+		if len(w.Url) == 0 {
+			v.fail(wp+".url", nil, "required")
+		}
+
+		// Nothing else tells that the wheel is the one that was tested
+		if !IsSha256(w.Hash) {
+			v.fail(wp+".hash", w.Hash, "expected the sha256 of the wheel")
+		}
+
+		for j, r := range w.Rules {
+			v.checkRule(fmt.Sprintf("%v.rules[%d]", wp, j), r)
+		}
+	}
+}
+
+func (v *manifestValidator) checkRule(path string, r Rule) {
+	if r.Action != RuleActionAllow && r.Action != RuleActionDisallow {
+		v.fail(path+".action", r.Action, "expected %v or %v", RuleActionAllow, RuleActionDisallow)
+	}
+}
+
+// Decodes a manifest document in out, migrating it to the current schema
+// The manifests made for a newer bootstrap are still loaded as long as they
+// make sense for this one, their unknown keys are only reported
+//
+// The returned validator is used by the caller to check what the types can't tell
+func decodeManifest(data []byte, out any) (*manifestValidator, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var document any
+	if err := decoder.Decode(&document); err != nil {
+		return nil, err
+	}
+
+	raw, ok := document.(map[string]any)
+	if !ok {
+		return nil, &ManifestValidationError{Errors: []ManifestFieldError{{Value: document, Message: "expected an object"}}}
+	}
+
+	schemaVersion := 1
+	if value, ok := raw["schema_version"]; ok {
+		n, ok := value.(json.Number)
+		version, err := n.Int64()
+		if !ok || err != nil || version < 1 {
+			return nil, &ManifestValidationError{Errors: []ManifestFieldError{{Path: "schema_version", Value: value, Message: "expected a positive integer"}}}
+		}
+
+		schemaVersion = int(version)
+	}
+
+	for v := schemaVersion; v < MANIFEST_SCHEMA_VERSION; v++ {
+		migrate, ok := manifestMigrations[v]
+		if !ok {
+			// Only new keys in this version, nothing to change
+			continue
+		}
+
+		if err := migrate(raw); err != nil {
+			return nil, fmt.Errorf("failed to migrate the manifest from schema %v: %w", v, err)
+		}
+	}
+
+	// The bootstraps already installed only know the jre.component keys, publishers keep them next to
+	// jre.runtimes for them. They are accepted by every schema and ignored when jre.runtimes is set
+	if err := migrateManifestV1(raw); err != nil {
+		return nil, fmt.Errorf("failed to read the legacy java keys: %w", err)
+	}
+	raw["schema_version"] = json.Number(fmt.Sprint(MANIFEST_SCHEMA_VERSION))
+
+	validator := &manifestValidator{strict: schemaVersion <= MANIFEST_SCHEMA_VERSION}
+	validator.check("", raw, reflect.TypeOf(out).Elem())
+
+	if len(validator.errors) > 0 {
+		return nil, &ManifestValidationError{Errors: validator.errors}
+	}
+
+	for _, w := range validator.warnings {
+		fmt.Printf("Manifest schema %v is newer than this bootstrap, ignoring %v\n", schemaVersion, w.String())
+	}
+
+	migrated, err := json.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	return validator, json.Unmarshal(migrated, out)
+}
+
+func (lm *LauncherManifest) UnmarshalJSON(data []byte) error {
+	// The alias drops the methods so that we don't end up back here
+	type launcherManifest LauncherManifest
+
+	var decoded launcherManifest
+	validator, err := decodeManifest(data, &decoded)
+	if err != nil {
+		return err
+	}
+
+	validator.checkLauncherManifest((*LauncherManifest)(&decoded))
+	if len(validator.errors) > 0 {
+		return &ManifestValidationError{Errors: validator.errors}
+	}
+
+	*lm = LauncherManifest(decoded)
+
+	return nil
+}
+
+func (mm *ManifestModule) UnmarshalJSON(data []byte) error {
+	type manifestModule ManifestModule
+
+	var decoded manifestModule
+	validator, err := decodeManifest(data, &decoded)
+	if err != nil {
+		return err
+	}
+
+	validator.checkManifestModule((*ManifestModule)(&decoded))
+	if len(validator.errors) > 0 {
+		return &ManifestValidationError{Errors: validator.errors}
+	}
+
+	*mm = ManifestModule(decoded)
+
+	return nil
+}
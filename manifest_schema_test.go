@@ -0,0 +1,134 @@
+/**
+ * Spectrum-Bootstrap - A bootstrap for Minecraft launchers
+ * Copyright (C) 2023-2024 - Oxodao
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ **/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func runTestMigration(t *testing.T, migrate func(map[string]any) error, input string) string {
+	manifest := map[string]any{}
+	if err := json.Unmarshal([]byte(input), &manifest); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := migrate(manifest); err != nil {
+		t.Fatal(err)
+	}
+
+	migrated, err := json.Marshal(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(migrated)
+}
+
+func TestMigrateManifestV1(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"no jre", `{"version":"1"}`, `{"version":"1"}`},
+		{"camel case", `{"jre":{"component":"gamma","componentLegacy":"legacy"}}`, `{"jre":{"runtimes":[{"component":"gamma","name":"main"},{"component":"legacy","name":"legacy"}]}}`},
+		{"snake case", `{"jre":{"manifest":"m","component":"gamma","component_legacy":"legacy"}}`, `{"jre":{"manifest":"m","runtimes":[{"component":"gamma","name":"main"},{"component":"legacy","name":"legacy"}]}}`},
+		{"both keys", `{"jre":{"componentLegacy":"old","component_legacy":"new"}}`, `{"jre":{"runtimes":[{"component":"new","name":"legacy"}]}}`},
+		{"unused legacy component", `{"jre":{"component":"gamma","component_legacy":""}}`, `{"jre":{"runtimes":[{"component":"gamma","name":"main"}]}}`},
+		{"no component", `{"jre":{"manifest":"m"}}`, `{"jre":{"manifest":"m","runtimes":[]}}`},
+		{
+			"runtimes win over the legacy keys",
+			`{"jre":{"component":"gamma","componentLegacy":"legacy","runtimes":[{"name":"main","component":"delta"}]}}`,
+			`{"jre":{"runtimes":[{"component":"delta","name":"main"}]}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if migrated := runTestMigration(t, migrateManifestV1, tt.input); migrated != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, migrated)
+			}
+		})
+	}
+}
+
+func TestUnmarshalLauncherManifest(t *testing.T) {
+	jre := `"jre": {"manifest": "https://example.com/java.json", "runtimes": [{"name": "main", "component": "gamma"}]}`
+	current := fmt.Sprintf(`"schema_version": %d, "version": "1", "main_class": "Main", `+jre, MANIFEST_SCHEMA_VERSION)
+	legacy := fmt.Sprintf(`"schema_version": %d, "version": "1", "main_class": "Main"`, MANIFEST_SCHEMA_VERSION)
+	python := fmt.Sprintf(`"schema_version": %d, "version": "1", "kind": "python"`, MANIFEST_SCHEMA_VERSION)
+	newer := fmt.Sprintf(`"schema_version": %d, "version": "1", "main_class": "Main", `+jre, MANIFEST_SCHEMA_VERSION+1)
+
+	tests := []struct {
+		name     string
+		input    string
+		runtimes []string
+		err      string
+	}{
+		{"current schema", `{` + current + `}`, []string{"main"}, ""},
+		{"no schema is the first one", `{"version": "1", "main_class": "Main", "jre": {"manifest": "m", "component": "gamma", "componentLegacy": "legacy"}}`, []string{"main", "legacy"}, ""},
+		{"legacy keys in the current schema", `{` + legacy + `, "jre": {"manifest": "m", "component": "gamma"}}`, []string{"main"}, ""},
+		{"legacy keys next to the runtimes", `{` + legacy + `, "jre": {"manifest": "m", "component": "gamma", "componentLegacy": "legacy", "runtimes": [{"name": "main", "component": "delta"}]}}`, []string{"main"}, ""},
+		{"unknown key", `{` + current + `, "versoin": "1"}`, nil, "versoin: unknown key"},
+		{"unknown nested key", `{` + current + `, "files": [{"type": "file", "pth": "a"}]}`, nil, "files[0].pth: unknown key"},
+		{"unknown key of a newer schema", `{` + newer + `, "future": true}`, []string{"main"}, ""},
+		{"wrong type", `{"version": 1, "main_class": "Main"}`, nil, "version: expected a string"},
+		{"wrong type in a newer schema", `{` + newer + `, "args": "--portable"}`, nil, "args: expected an array"},
+		{"missing key", `{"version": "1"}`, nil, "main_class: required"},
+		{"no runtime", `{` + legacy + `, "jre": {"manifest": "m"}}`, nil, "jre.runtimes: at least one runtime is required"},
+		{"component outside of the runtimes", `{` + legacy + `, "jre": {"manifest": "m", "runtimes": [{"name": "main", "component": "../evil"}]}}`, nil, "jre.runtimes[0].component: expected letters"},
+		{"archive without sha256", `{` + current + `, "files": [{"type": "archive", "path": "natives", "hash": "../../x", "url": "https://example.com/natives.zip"}]}`, nil, "files[0].hash: expected the sha256 of the archive"},
+		{"relative location", `{` + current + `, "location": "launcher_manifest.json"}`, nil, "location: expected the url the manifest is published at"},
+		{"moved to another scheme", `{` + current + `, "moved_to": "ftp://example.com/launcher_manifest.json"}`, nil, "moved_to: expected the url of the new manifest"},
+		{"relocation stub", fmt.Sprintf(`{"schema_version": %d, "version": "1", "moved_to": "https://example.com/launcher_manifest.json"}`, MANIFEST_SCHEMA_VERSION), nil, ""},
+		{"relocation stub of an unknown kind", fmt.Sprintf(`{"schema_version": %d, "version": "1", "kind": "flash", "moved_to": "https://example.com/launcher_manifest.json"}`, MANIFEST_SCHEMA_VERSION), nil, "kind: expected one of"},
+		{"python version", `{` + python + `, "python": {"manifest": "https://example.com/python.json", "version": "3.12.7", "module": "app"}}`, nil, ""},
+		{"python version outside of the runtimes", `{` + python + `, "python": {"manifest": "https://example.com/python.json", "version": "../../evil", "module": "app"}}`, nil, "python.version: expected letters, digits, dots, dashes and underscores"},
+		{"invalid schema version", `{"schema_version": "2", "version": "1", "main_class": "Main"}`, nil, "schema_version: expected a positive integer"},
+		{"not an object", `[]`, nil, "expected an object"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var manifest LauncherManifest
+			err := json.Unmarshal([]byte(tt.input), &manifest)
+
+			if len(tt.err) > 0 {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("expected the error %q, got %v", tt.err, err)
+				}
+				return
+			} else if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+
+			names := []string{}
+			for _, r := range manifest.Java.Runtimes {
+				names = append(names, r.Name)
+			}
+
+			if strings.Join(names, ",") != strings.Join(tt.runtimes, ",") {
+				t.Errorf("expected the runtimes %v, got %v", tt.runtimes, names)
+			}
+		})
+	}
+}
@@ -18,12 +18,102 @@
 
 package main
 
+import (
+	"encoding/json"
+	"os"
+)
+
 type BootstrapSettings struct {
 	ManifestURL string `json:"launcher_manifest"`
 	Brand       string `json:"launcher_brand"`
 	FolderName  string `json:"launcher_foldername"`
 
-	LauncherPath string `json:"-"`
+	// Update channel name => launcher manifest url
+	Channels       map[string]string `json:"channels,omitempty"`
+	DefaultChannel string            `json:"default_channel,omitempty"`
+
+	// How many launcher versions are kept on disk for rollbacks
+	KeepVersions  int    `json:"launcher_keep_versions,omitempty"`
+	PinnedVersion string `json:"launcher_pinned_version,omitempty"`
+
+	// Feature flags used by the manifest rules
+	Features map[string]bool `json:"features,omitempty"`
+
+	// Optional settings fetched on each run, they override the ones above
+	SettingsURL string `json:"settings_url,omitempty"`
+
+	// When set, the launcher manifest and remote settings must be signed by one of these keys
+	PublicKeys []string `json:"public_keys,omitempty"`
+
+	// Url prefix => alternative prefixes to try when it fails
+	Mirrors map[string][]string `json:"mirrors,omitempty"`
+
+	// Launchers the player can pick from, see profiles.go
+	Profiles []Profile `json:"profiles,omitempty"`
+
+	// Java manifest key => the keys to try next when a runtime has no build for it, see platform.go
+	PlatformFallbacks map[string][]string `json:"platform_fallbacks,omitempty"`
+
+	// Keys allowed to sign the payload of a rebranded bootstrap
+	// Only read from the embedded settings
+	PayloadPublicKeys []string `json:"payload_public_keys,omitempty"`
+
+	LauncherPath string            `json:"-"`
+	RuntimePath  string            `json:"-"`
+	Portable     bool              `json:"-"`
+	Profile      string            `json:"-"`
+	Sources      map[string]string `json:"-"`
+	Icon         []byte            `json:"-"`
+	Channel      string            `json:"-"`
+	Platform     *Platform         `json:"-"`
+	Installation *Installation     `json:"-"`
+	Audit        *AuditLog         `json:"-"`
+}
+
+// Where the bootstrap runs, go names except for the libc
+type Platform struct {
+	Os   string
+	Arch string
+	// glibc or musl on linux, empty elsewhere
+	Libc string
+}
+
+// A launcher the bootstrap can start, stored in its own folder
+// Its settings override the bootstrap ones, i.e. launcher_manifest or launcher_brand
+type Profile struct {
+	ID       string                     `json:"id"`
+	Name     string                     `json:"name,omitempty"`
+	Settings map[string]json.RawMessage `json:"settings,omitempty"`
+}
+
+// Infos specific to this installation, stored in the launcher path
+type Installation struct {
+	RolloutBucket int `json:"rollout_bucket"`
+}
+
+// Staged rollout of a release
+// Progress is the percentage of installations receiving it
+// Group is an identifier of the rollout so that each one targets different installations
+type Rollout struct {
+	Group    int `json:"group"`
+	Progress int `json:"progress"`
+
+	// The manifest to use for the installations not yet in the rollout
+	// If not set, they keep the launcher version they have
+	FallbackManifest string `json:"fallback_manifest,omitempty"`
+}
+
+// Preferences of the player, stored in the launcher path
+type UserConfig struct {
+	Channel       string `json:"channel,omitempty"`
+	PinnedVersion string `json:"pinned_version,omitempty"`
+
+	// In megabytes, 0 to use the manifest's value
+	MinMemory int `json:"min_memory,omitempty"`
+	MaxMemory int `json:"max_memory,omitempty"`
+
+	// Empty to download java, "system" to look for an installed one or the path of one, see system_java.go
+	Java string `json:"java,omitempty"`
 }
 
 type LauncherVersion struct {
@@ -31,28 +121,175 @@ type LauncherVersion struct {
 	Hash    string `json:"hash"`
 }
 
+type RuleOs struct {
+	Name    string `json:"name,omitempty"`
+	Arch    string `json:"arch,omitempty"`
+	Version string `json:"version,omitempty"`
+}
+
+type Rule struct {
+	Action   string          `json:"action"`
+	Os       *RuleOs         `json:"os,omitempty"`
+	Features map[string]bool `json:"features,omitempty"`
+}
+
 type ManifestFile struct {
-	Type string `json:"type"`
-	Path string `json:"path"`
-	Hash string `json:"hash"`
-	Url  string `json:"url"`
-	Size int    `json:"size"`
+	Type  string `json:"type"`
+	Path  string `json:"path"`
+	Hash  string `json:"hash"`
+	Url   string `json:"url"`
+	Size  int    `json:"size"`
+	Rules []Rule `json:"rules,omitempty"`
+
+	// Unix permissions in octal (i.e. "0750"), otherwise 0755 for executables and 0644 for the other files
+	Executable bool   `json:"executable,omitempty"`
+	Mode       string `json:"mode,omitempty"`
+
+	// Only for the links, relative to the folder of the link
+	Target string `json:"target,omitempty"`
+
+	// Only for the archives, guessed from the url when not set
+	Format string `json:"format,omitempty"`
+}
+
+// What was extracted from an archive, relative to the folder it was extracted in
+// Files are listed with their sha256, links with their target
+type ArchiveIndex struct {
+	Files map[string]string `json:"files"`
+	Links map[string]string `json:"links,omitempty"`
+}
+
+type ConditionalArgument struct {
+	Rules  []Rule
+	Values []string
+}
+
+// In megabytes
+type MemorySettings struct {
+	Min int `json:"min,omitempty"`
+	Max int `json:"max,omitempty"`
+}
+
+// Environment variables of the launcher
+type EnvironmentSettings struct {
+	Set   map[string]string `json:"set,omitempty"`
+	Allow []string          `json:"allow,omitempty"`
+	Deny  []string          `json:"deny,omitempty"`
 }
 
 type LauncherJavaManifest struct {
 	ManifestURL string `json:"manifest"`
-	Component   string `json:"component"`
-	ComponentLegacy string `json:"componentLegacy"`
+	// The first runtime starts the launcher, see runtime_manager.go
+	Runtimes []LauncherRuntime `json:"runtimes"`
 }
 
+type LauncherRuntime struct {
+	// Used by the placeholders, i.e. ${legacyJavaExecutable} for "legacy"
+	Name      string `json:"name"`
+	Component string `json:"component"`
+	// Which build of the component to use, see SelectJavaVersionConstraint
+	Version string `json:"version,omitempty"`
+	// Skipped instead of failing when there is no build of it for this computer
+	Optional bool `json:"optional,omitempty"`
+	// Whether the players can use their own java instead, true when not set
+	AllowSystem *bool `json:"allow_system,omitempty"`
+
+	// "manifest" (the default) to use the java manifest, "archive" to download one of the archives
+	Source   string           `json:"source,omitempty"`
+	Archives []RuntimeArchive `json:"archives,omitempty"`
+}
+
+// A JDK or JRE as published by its vendor, i.e. an Adoptium tarball
+type RuntimeArchive struct {
+	Rules  []Rule `json:"rules,omitempty"`
+	Url    string `json:"url"`
+	Hash   string `json:"hash"`
+	Size   int    `json:"size,omitempty"`
+	Format string `json:"format,omitempty"`
+	// The name of the build, recorded with the installed runtime
+	Version string `json:"version,omitempty"`
+}
+
+// See manifest_schema.go for the validation and the migrations from the older schemas
 type LauncherManifest struct {
-	Version   string               `json:"version"`
-	Files     []ManifestFile       `json:"files"`
-	MainClass string               `json:"main_class"`
-	Args      []string             `json:"args"`
-	Java      LauncherJavaManifest `json:"jre"`
+	SchemaVersion int    `json:"schema_version"`
+	Version       string `json:"version"`
+
+	// How the launcher is started, see launch.go
+	// Java launchers need main_class and jre, executable ones need executable, python ones need python
+	Kind       string                  `json:"kind,omitempty"`
+	Executable string                  `json:"executable,omitempty"`
+	Python     *LauncherPythonManifest `json:"python,omitempty"`
+
+	Rollout   *Rollout              `json:"rollout,omitempty"`
+	Files     []ManifestFile        `json:"files"`
+	Preserve  []string              `json:"preserve,omitempty"`
+	MainClass string                `json:"main_class"`
+	Memory    MemorySettings        `json:"memory,omitempty"`
+	JvmArgs   []ConditionalArgument `json:"jvm_args,omitempty"`
+	Args      []string              `json:"args"`
+	Env       EnvironmentSettings   `json:"env,omitempty"`
+	Java      LauncherJavaManifest  `json:"jre"`
+
+	// Where the manifest now lives, see relocations.go
+	MovedTo string `json:"moved_to,omitempty"`
+
+	// The url the manifest is published at, a move to it is only remembered when it names it
+	Location string `json:"location,omitempty"`
+
+	// Modules merged in this manifest, see manifest_modules.go
+	Includes []ManifestInclude `json:"includes,omitempty"`
+}
+
+// A module referenced by a manifest
+// The hash is the sha256 of the module document, so that it can't change without the manifest changing too
+type ManifestInclude struct {
+	Url  string `json:"url"`
+	Hash string `json:"hash"`
+}
+
+// A part of a launcher manifest maintained on its own, i.e. libraries shared by several launchers
+type ManifestModule struct {
+	SchemaVersion int                   `json:"schema_version"`
+	Name          string                `json:"name,omitempty"`
+	Files         []ManifestFile        `json:"files"`
+	Preserve      []string              `json:"preserve,omitempty"`
+	JvmArgs       []ConditionalArgument `json:"jvm_args,omitempty"`
+	Includes      []ManifestInclude     `json:"includes,omitempty"`
+}
+
+// See python_manager.go
+type LauncherPythonManifest struct {
+	ManifestURL string        `json:"manifest"`
+	Version     string        `json:"version"`
+	Wheels      []PythonWheel `json:"wheels,omitempty"`
+
+	// Either a module run with -m or a script of the launcher folder
+	Module string `json:"module,omitempty"`
+	Script string `json:"script,omitempty"`
+}
+
+type PythonWheel struct {
+	Name  string `json:"name,omitempty"`
+	Url   string `json:"url"`
+	Hash  string `json:"hash"`
+	Rules []Rule `json:"rules,omitempty"`
 }
 
+// A relocatable build of python, i.e. from python-build-standalone
+type PythonDistribution struct {
+	Rules  []Rule `json:"rules,omitempty"`
+	Url    string `json:"url"`
+	Hash   string `json:"hash"`
+	Size   int    `json:"size"`
+	Format string `json:"format,omitempty"`
+	// The interpreter in the archive, i.e. python/bin/python3
+	Executable string `json:"executable"`
+}
+
+// Version => the builds of this version, the first one whose rules match is used
+type PythonManifest map[string][]PythonDistribution
+
 type JavaManifestFileDownload struct {
 	Hash string `json:"sha1"`
 	Size int    `json:"size"`
@@ -62,6 +299,7 @@ type JavaManifestFileDownload struct {
 type JavaManifestFile struct {
 	Type       string `json:"type"`
 	Executable bool   `json:"executable"`
+	Target     string `json:"target,omitempty"`
 	Downloads  struct {
 		LZMA JavaManifestFileDownload `json:"lzma"`
 		Raw  JavaManifestFileDownload `json:"raw"`
@@ -73,12 +311,9 @@ type JavaManifest struct {
 }
 
 type MainJavaManifestVersion struct {
-	Availability struct {
-		Group    int `json:"group"`
-		Progress int `json:"progress"`
-	} `json:"availability"`
-	Manifest JavaManifestFileDownload `json:"manifest"`
-	Version  struct {
+	Availability *Rollout                 `json:"availability"`
+	Manifest     JavaManifestFileDownload `json:"manifest"`
+	Version      struct {
 		Name     string `json:"name"`
 		Released string `json:"released"`
 	} `json:"version"`
@@ -93,4 +328,9 @@ type Downloadable struct {
 	Sha256     string
 	Size       int
 	Executable bool
+	// Overrides the executable flag when set
+	Mode os.FileMode
+
+	// sha256 of the file being replaced, empty if the file did not exist
+	PreviousHash string
 }
@@ -0,0 +1,220 @@
+/**
+ * Spectrum-Bootstrap - A bootstrap for Minecraft launchers
+ * Copyright (C) 2023-2024 - Oxodao
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ **/
+
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// A prebuilt bootstrap can be rebranded by appending a payload to its executable:
+//
+//	[settings json][icon png][signature][trailer]
+//
+// The trailer is at the very end of the file so that we can find the payload
+// without knowing the size of the executable:
+//
+//	settings length (uint32) | icon length (uint32) | signature length (uint32) | sha256 of settings+icon | magic
+const PAYLOAD_MAGIC = "LBSPAYL1"
+
+const PAYLOAD_TRAILER_SIZE = 4 + 4 + 4 + sha256.Size + len(PAYLOAD_MAGIC)
+
+var (
+	ErrPayloadCorrupted = errors.New("the bootstrap payload is corrupted")
+	ErrPayloadUnsigned  = errors.New("the bootstrap payload is not signed by a trusted key")
+)
+
+type Payload struct {
+	Settings  []byte
+	Icon      []byte
+	Signature []byte
+}
+
+// The data covered by the signature
+func (p *Payload) SignedData() []byte {
+	return append(append([]byte{}, p.Settings...), p.Icon...)
+}
+
+func (p *Payload) Sign(key ed25519.PrivateKey) {
+	p.Signature = ed25519.Sign(key, p.SignedData())
+}
+
+func (p *Payload) Verify(keys []ed25519.PublicKey) error {
+	if len(p.Signature) == 0 {
+		return ErrPayloadUnsigned
+	}
+
+	return VerifySignature(keys, p.SignedData(), p.Signature)
+}
+
+// Reads the payload at the end of the given executable
+// Returns a nil payload if there is none, and the size of the executable without it
+func ReadPayload(path string) (*Payload, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	size := fi.Size()
+	if size < int64(PAYLOAD_TRAILER_SIZE) {
+		return nil, size, nil
+	}
+
+	trailer := make([]byte, PAYLOAD_TRAILER_SIZE)
+	if _, err := f.ReadAt(trailer, size-int64(PAYLOAD_TRAILER_SIZE)); err != nil {
+		return nil, 0, err
+	}
+
+	if string(trailer[PAYLOAD_TRAILER_SIZE-len(PAYLOAD_MAGIC):]) != PAYLOAD_MAGIC {
+		return nil, size, nil
+	}
+
+	settingsLen := int64(binary.LittleEndian.Uint32(trailer[0:4]))
+	iconLen := int64(binary.LittleEndian.Uint32(trailer[4:8]))
+	signatureLen := int64(binary.LittleEndian.Uint32(trailer[8:12]))
+	checksum := trailer[12 : 12+sha256.Size]
+
+	payloadLen := settingsLen + iconLen + signatureLen
+	start := size - int64(PAYLOAD_TRAILER_SIZE) - payloadLen
+	if start < 0 {
+		return nil, 0, ErrPayloadCorrupted
+	}
+
+	data := make([]byte, payloadLen)
+	if _, err := f.ReadAt(data, start); err != nil {
+		return nil, 0, err
+	}
+
+	payload := &Payload{
+		Settings:  data[:settingsLen],
+		Icon:      data[settingsLen : settingsLen+iconLen],
+		Signature: data[settingsLen+iconLen:],
+	}
+
+	sum := sha256.Sum256(payload.SignedData())
+	if !bytes.Equal(sum[:], checksum) {
+		return nil, 0, ErrPayloadCorrupted
+	}
+
+	return payload, start, nil
+}
+
+func (p *Payload) WriteTo(w io.Writer) (int64, error) {
+	trailer := make([]byte, 12, PAYLOAD_TRAILER_SIZE)
+	binary.LittleEndian.PutUint32(trailer[0:4], uint32(len(p.Settings)))
+	binary.LittleEndian.PutUint32(trailer[4:8], uint32(len(p.Icon)))
+	binary.LittleEndian.PutUint32(trailer[8:12], uint32(len(p.Signature)))
+
+	sum := sha256.Sum256(p.SignedData())
+	trailer = append(trailer, sum[:]...)
+	trailer = append(trailer, PAYLOAD_MAGIC...)
+
+	written := int64(0)
+	for _, part := range [][]byte{p.Settings, p.Icon, p.Signature, trailer} {
+		n, err := w.Write(part)
+		written += int64(n)
+		if err != nil {
+			return written, err
+		}
+	}
+
+	return written, nil
+}
+
+// Copies the executable without its payload, then appends the new one
+// It is written next to out then moved over it, in and out can be the same file
+func WriteExecutableWithPayload(in, out string, p *Payload) error {
+	_, size, err := ReadPayload(in)
+	if err != nil {
+		return err
+	}
+
+	src, err := os.Open(in)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.CreateTemp(filepath.Dir(out), "."+filepath.Base(out)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(dst.Name())
+	defer dst.Close()
+
+	if _, err := io.CopyN(dst, src, size); err != nil {
+		return err
+	}
+
+	// Windows can't replace a file that is still open
+	src.Close()
+
+	if _, err := p.WriteTo(dst); err != nil {
+		return err
+	}
+
+	if err := dst.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Chmod(dst.Name(), 0755); err != nil {
+		return err
+	}
+
+	return os.Rename(dst.Name(), out)
+}
+
+// Reads the payload of the running bootstrap
+// When the embedded settings declare trusted keys, the payload must be signed by one of them
+func LoadOwnPayload(trustedKeys []string) (*Payload, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+
+	payload, _, err := ReadPayload(executable)
+	if err != nil || payload == nil {
+		return nil, err
+	}
+
+	if len(trustedKeys) > 0 {
+		keys, err := ParsePublicKeys(trustedKeys)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := payload.Verify(keys); err != nil {
+			return nil, ErrPayloadUnsigned
+		}
+	}
+
+	return payload, nil
+}
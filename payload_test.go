@@ -0,0 +1,157 @@
+/**
+ * Spectrum-Bootstrap - A bootstrap for Minecraft launchers
+ * Copyright (C) 2023-2024 - Oxodao
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ **/
+
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testExecutable = "\x7fELF not really an executable"
+
+func writePayloadFile(t *testing.T, p *Payload, corrupt func([]byte) []byte) string {
+	var buf bytes.Buffer
+	buf.WriteString(testExecutable)
+
+	if p != nil {
+		if _, err := p.WriteTo(&buf); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	data := buf.Bytes()
+	if corrupt != nil {
+		data = corrupt(data)
+	}
+
+	path := filepath.Join(t.TempDir(), "bootstrap")
+	if err := os.WriteFile(path, data, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestReadPayload(t *testing.T) {
+	payload := &Payload{Settings: []byte(`{"launcher_brand": "Rebranded"}`), Icon: []byte("png")}
+
+	tests := []struct {
+		name     string
+		payload  *Payload
+		corrupt  func([]byte) []byte
+		expected *Payload
+		size     int64
+		err      error
+	}{
+		{"no payload", nil, nil, nil, int64(len(testExecutable)), nil},
+		{"payload", payload, nil, payload, int64(len(testExecutable)), nil},
+		{"empty payload", &Payload{}, nil, &Payload{Settings: []byte{}, Icon: []byte{}, Signature: []byte{}}, int64(len(testExecutable)), nil},
+		{
+			"modified settings",
+			payload,
+			func(data []byte) []byte {
+				data[len(testExecutable)] = '['
+				return data
+			},
+			nil, 0, ErrPayloadCorrupted,
+		},
+		{
+			"lengths larger than the file",
+			payload,
+			func(data []byte) []byte {
+				trailer := data[len(data)-PAYLOAD_TRAILER_SIZE:]
+				trailer[3] = 0x7f
+				return data
+			},
+			nil, 0, ErrPayloadCorrupted,
+		},
+		{
+			"truncated magic",
+			payload,
+			func(data []byte) []byte {
+				return data[:len(data)-1]
+			},
+			nil, int64(len(testExecutable)) + int64(len(payload.Settings)+len(payload.Icon)) + int64(PAYLOAD_TRAILER_SIZE) - 1, nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, size, err := ReadPayload(writePayloadFile(t, tt.payload, tt.corrupt))
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("expected the error %v, got %v", tt.err, err)
+			}
+
+			if size != tt.size {
+				t.Errorf("expected the size %v, got %v", tt.size, size)
+			}
+
+			if (p == nil) != (tt.expected == nil) {
+				t.Fatalf("expected the payload %v, got %v", tt.expected, p)
+			}
+
+			if p != nil && (!bytes.Equal(p.Settings, tt.expected.Settings) || !bytes.Equal(p.Icon, tt.expected.Icon) || !bytes.Equal(p.Signature, tt.expected.Signature)) {
+				t.Errorf("expected the payload %+v, got %+v", tt.expected, p)
+			}
+		})
+	}
+}
+
+func TestWriteExecutableWithPayload(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := writePayloadFile(t, &Payload{Settings: []byte(`{"launcher_brand": "First"}`)}, nil)
+
+	// Rebranding in place replaces the previous payload
+	payload := &Payload{Settings: []byte(`{"launcher_brand": "Second"}`)}
+	payload.Sign(privateKey)
+	if err := WriteExecutableWithPayload(path, path, payload); err != nil {
+		t.Fatal(err)
+	}
+
+	p, size, err := ReadPayload(path)
+	if err != nil || p == nil {
+		t.Fatalf("expected a payload, got %v (%v)", p, err)
+	}
+
+	if size != int64(len(testExecutable)) {
+		t.Errorf("expected the size of the executable %v, got %v", len(testExecutable), size)
+	}
+
+	if string(p.Settings) != `{"launcher_brand": "Second"}` {
+		t.Errorf("expected the new settings, got %s", p.Settings)
+	}
+
+	if err := p.Verify([]ed25519.PublicKey{publicKey}); err != nil {
+		t.Errorf("expected a valid signature, got %v", err)
+	}
+
+	p.Settings = []byte(`{"launcher_brand": "Tampered"}`)
+	if err := p.Verify([]ed25519.PublicKey{publicKey}); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("expected %v, got %v", ErrInvalidSignature, err)
+	}
+}
@@ -0,0 +1,97 @@
+/**
+ * Spectrum-Bootstrap - A bootstrap for Minecraft launchers
+ * Copyright (C) 2023-2024 - Oxodao
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ **/
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"strconv"
+)
+
+const (
+	DIR_MODE        os.FileMode = 0755
+	FILE_MODE       os.FileMode = 0644
+	EXECUTABLE_MODE os.FileMode = 0755
+)
+
+var ErrInvalidMode = errors.New("invalid mode")
+
+// Unix permissions written in octal, i.e. "0750"
+func ParseFileMode(mode string) (os.FileMode, error) {
+	parsed, err := strconv.ParseUint(mode, 8, 32)
+	if err != nil || parsed > 0777 {
+		return 0, fmt.Errorf("%w: %v", ErrInvalidMode, mode)
+	}
+
+	return os.FileMode(parsed), nil
+}
+
+// The explicit mode wins over the executable flag
+// An invalid mode can't get here as the manifest validation refuses it
+func (f ManifestFile) GetMode() os.FileMode {
+	if len(f.Mode) > 0 {
+		if mode, err := ParseFileMode(f.Mode); err == nil {
+			return mode
+		}
+	}
+
+	if f.Type == "directory" {
+		return DIR_MODE
+	}
+
+	if f.Executable {
+		return EXECUTABLE_MODE
+	}
+
+	return FILE_MODE
+}
+
+func (d Downloadable) GetMode() os.FileMode {
+	if d.Mode != 0 {
+		return d.Mode
+	}
+
+	if d.Executable {
+		return EXECUTABLE_MODE
+	}
+
+	return FILE_MODE
+}
+
+// Changes the permissions of the file when they are not the expected ones
+// Returns whether they were changed
+// Windows has no such permissions, nothing is done there
+func EnsureFileMode(path string, mode os.FileMode) (bool, error) {
+	if runtime.GOOS == "windows" {
+		return false, nil
+	}
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		return false, err
+	}
+
+	if fi.Mode().Perm() == mode.Perm() {
+		return false, nil
+	}
+
+	return true, os.Chmod(path, mode.Perm())
+}
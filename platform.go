@@ -0,0 +1,203 @@
+/**
+ * Spectrum-Bootstrap - A bootstrap for Minecraft launchers
+ * Copyright (C) 2023-2024 - Oxodao
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ **/
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+)
+
+const (
+	LibcGlibc = "glibc"
+	LibcMusl  = "musl"
+)
+
+var ErrInvalidPlatform = errors.New("invalid platform")
+
+var (
+	platformOses   = []string{"linux", "darwin", "windows"}
+	platformArches = []string{"386", "amd64", "arm", "arm64"}
+)
+
+// The java manifest keys to try when a runtime has no build for the platform
+// Both macOS and Windows run the x64 builds on arm64 computers, the glibc builds don't run on musl
+var DefaultPlatformFallbacks = map[string][]string{
+	"mac-os-arm64":  {"mac-os"},
+	"windows-arm64": {"windows-x64"},
+}
+
+// Musl based distributions, i.e. Alpine, have its loader instead of the glibc one
+// Debian and Ubuntu can install the musl loader next to the glibc one, glibc wins then
+func detectLibc() string {
+	glibcLoaders := []string{"/lib64/ld-linux-*.so.*", "/lib/ld-linux*.so.*", "/lib/*-linux-gnu*/ld-linux*.so.*", "/usr/lib/*-linux-gnu*/ld-linux*.so.*"}
+	for _, pattern := range glibcLoaders {
+		if matches, _ := filepath.Glob(pattern); len(matches) > 0 {
+			return LibcGlibc
+		}
+	}
+
+	for _, pattern := range []string{"/lib/ld-musl-*.so.1", "/usr/lib/ld-musl-*.so.1"} {
+		if matches, _ := filepath.Glob(pattern); len(matches) > 0 {
+			return LibcMusl
+		}
+	}
+
+	return LibcGlibc
+}
+
+func DetectPlatform() Platform {
+	p := Platform{Os: runtime.GOOS, Arch: runtime.GOARCH}
+	if p.Arch == "amd64p32" {
+		p.Arch = "amd64"
+	}
+
+	if p.Os == "linux" {
+		p.Libc = detectLibc()
+	}
+
+	return p
+}
+
+// i.e. linux/arm64, linux/amd64/musl or windows/amd64
+func ParsePlatform(value string) (Platform, error) {
+	parts := strings.Split(value, "/")
+	if len(parts) < 2 || len(parts) > 3 {
+		return Platform{}, fmt.Errorf("%w: %q, expected os/arch or linux/arch/libc", ErrInvalidPlatform, value)
+	}
+
+	p := Platform{Os: parts[0], Arch: parts[1]}
+	if !slices.Contains(platformOses, p.Os) {
+		return Platform{}, fmt.Errorf("%w: unknown os %q, expected one of %v", ErrInvalidPlatform, p.Os, strings.Join(platformOses, ", "))
+	}
+
+	if !slices.Contains(platformArches, p.Arch) {
+		return Platform{}, fmt.Errorf("%w: unknown arch %q, expected one of %v", ErrInvalidPlatform, p.Arch, strings.Join(platformArches, ", "))
+	}
+
+	if p.Os == "linux" {
+		p.Libc = LibcGlibc
+		if len(parts) == 3 {
+			p.Libc = parts[2]
+		}
+
+		if p.Libc != LibcGlibc && p.Libc != LibcMusl {
+			return Platform{}, fmt.Errorf("%w: unknown libc %q, expected %v or %v", ErrInvalidPlatform, p.Libc, LibcGlibc, LibcMusl)
+		}
+	} else if len(parts) == 3 {
+		return Platform{}, fmt.Errorf("%w: the libc is only for linux", ErrInvalidPlatform)
+	}
+
+	return p, nil
+}
+
+func (p Platform) String() string {
+	if len(p.Libc) > 0 {
+		return p.Os + "/" + p.Arch + "/" + p.Libc
+	}
+
+	return p.Os + "/" + p.Arch
+}
+
+// The key of the java manifests for this platform, as Mojang names them
+// The ones Mojang doesn't have (linux-arm64, the musl builds) follow the same naming
+func (p Platform) GetJavaOs() (string, error) {
+	var key string
+
+	switch p.Os {
+	case "linux":
+		switch p.Arch {
+		case "amd64":
+			key = "linux"
+		case "386":
+			key = "linux-i386"
+		case "arm64":
+			key = "linux-arm64"
+		case "arm":
+			key = "linux-arm32"
+		}
+
+		if len(key) > 0 && p.Libc == LibcMusl {
+			key += "-musl"
+		}
+	case "darwin":
+		switch p.Arch {
+		case "amd64":
+			key = "mac-os"
+		case "arm64":
+			key = "mac-os-arm64"
+		}
+	case "windows":
+		switch p.Arch {
+		case "386":
+			key = "windows-x86"
+		case "amd64":
+			key = "windows-x64"
+		case "arm64":
+			key = "windows-arm64"
+		}
+	}
+
+	if len(key) == 0 {
+		return "", fmt.Errorf("%w: %v", ErrFailedDetermineOs, p)
+	}
+
+	return key, nil
+}
+
+// The java manifest keys to try in order, the settings can replace the default fallbacks of a key
+func (p Platform) GetJavaOsKeys(fallbacks map[string][]string) ([]string, error) {
+	key, err := p.GetJavaOs()
+	if err != nil {
+		return nil, err
+	}
+
+	keys := []string{}
+	queue := []string{key}
+	for len(queue) > 0 {
+		k := queue[0]
+		queue = queue[1:]
+
+		if slices.Contains(keys, k) {
+			continue
+		}
+		keys = append(keys, k)
+
+		next, ok := fallbacks[k]
+		if !ok {
+			next = DefaultPlatformFallbacks[k]
+		}
+		queue = append(queue, next...)
+	}
+
+	return keys, nil
+}
+
+// The platform given with --platform, or the detected one
+func (bs *BootstrapSettings) GetPlatform() Platform {
+	if bs.Platform == nil {
+		p := DetectPlatform()
+		bs.Platform = &p
+	}
+
+	return *bs.Platform
+}
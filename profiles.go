@@ -0,0 +1,148 @@
+/**
+ * Spectrum-Bootstrap - A bootstrap for Minecraft launchers
+ * Copyright (C) 2023-2024 - Oxodao
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ **/
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Each profile gets its own launcher path in this folder
+// The runtimes stay at the root so that the profiles share them
+const PROFILES_DIR = "profiles"
+
+var (
+	ErrUnknownProfile = errors.New("unknown profile")
+	ErrInvalidProfile = errors.New("invalid profile")
+)
+
+// Keys that only make sense for the whole bootstrap
+var profileForbiddenKeys = []string{
+	"launcher_foldername",
+	"profiles",
+	"settings_url",
+	"public_keys",
+	"payload_public_keys",
+}
+
+// Called when the player has to pick a profile, returns its id
+type ProfileChooser func(profiles []Profile) (string, error)
+
+func (p Profile) GetDisplayName() string {
+	if len(p.Name) > 0 {
+		return p.Name
+	}
+
+	return p.ID
+}
+
+// The id is used as a folder name
+func (p Profile) Validate() error {
+	if len(p.ID) == 0 || p.ID == "." || p.ID == ".." || strings.ContainsAny(p.ID, `/\:`) {
+		return fmt.Errorf("%w: %q can't be used as an id", ErrInvalidProfile, p.ID)
+	}
+
+	return nil
+}
+
+func (p Profile) GetSettingsLayer() *SettingsLayer {
+	layer := &SettingsLayer{Name: "profile " + p.ID, Values: map[string]json.RawMessage{}}
+
+	for k, v := range p.Settings {
+		layer.Values[k] = v
+	}
+
+	for _, k := range profileForbiddenKeys {
+		if _, ok := layer.Values[k]; ok {
+			fmt.Printf("The profile %v can't change %v, ignoring it.\n", p.ID, k)
+			delete(layer.Values, k)
+		}
+	}
+
+	return layer
+}
+
+func (bs *BootstrapSettings) FindProfile(id string) (*Profile, error) {
+	for _, p := range bs.Profiles {
+		if p.ID == id {
+			if err := p.Validate(); err != nil {
+				return nil, err
+			}
+
+			return &p, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: %v", ErrUnknownProfile, id)
+}
+
+// Picks the profile to start:
+// - the requested one (--profile)
+// - the only one
+// - the one chosen by the player, when we can ask
+// - the first one otherwise
+// Returns an empty id when the bootstrap has no profiles
+func (bs *BootstrapSettings) SelectProfile(requested string, choose ProfileChooser) (string, error) {
+	if len(bs.Profiles) == 0 {
+		if len(requested) > 0 {
+			return "", fmt.Errorf("%w: %v, this bootstrap has no profiles", ErrUnknownProfile, requested)
+		}
+
+		return "", nil
+	}
+
+	id := requested
+	if len(id) == 0 && len(bs.Profiles) == 1 {
+		id = bs.Profiles[0].ID
+	} else if len(id) == 0 && choose != nil {
+		var err error
+		id, err = choose(bs.Profiles)
+		if err != nil {
+			return "", err
+		}
+	} else if len(id) == 0 {
+		id = bs.Profiles[0].ID
+		fmt.Printf("Using the profile %v, use --profile to pick another one.\n", id)
+	}
+
+	if _, err := bs.FindProfile(id); err != nil {
+		return "", err
+	}
+
+	return id, nil
+}
+
+// Moves the launcher path in the profile folder
+// Must be called once the launcher path is known
+func (bs *BootstrapSettings) UseProfile(id string) error {
+	bs.RuntimePath = bs.LauncherPath
+	bs.Profile = id
+
+	if len(id) == 0 {
+		return nil
+	}
+
+	bs.LauncherPath = filepath.Join(bs.LauncherPath, PROFILES_DIR, id)
+
+	return os.MkdirAll(bs.LauncherPath, DIR_MODE)
+}
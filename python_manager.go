@@ -0,0 +1,372 @@
+/**
+ * Spectrum-Bootstrap - A bootstrap for Minecraft launchers
+ * Copyright (C) 2023-2024 - Oxodao
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ **/
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// The wheels are installed here, in the launcher folder of the profile
+// It is given to python as its PYTHONPATH, nothing from the player's python is used
+const PYTHON_ENV_DIR = "python-env"
+
+var (
+	ErrNoPythonVersion = errors.New("python version not found in the manifest")
+	ErrNoPythonForOs   = errors.New("no python distribution found for this os")
+	ErrInvalidPython   = errors.New("invalid python distribution")
+)
+
+// The version a python distribution was installed from, next to its folder
+type InstalledPython struct {
+	Version string `json:"version"`
+	Archive string `json:"archive"`
+}
+
+type PythonManager struct {
+	cachedManifest *PythonManifest
+	distribution   PythonDistribution
+
+	launcherManifest LauncherPythonManifest
+	bSettings        *BootstrapSettings
+
+	// What ValidateInstallation found missing, installed by InstallPending once downloaded
+	pendingDistribution bool
+	pendingWheels       []PythonWheel
+}
+
+func GetPythonManager(bs *BootstrapSettings, launcherManifest LauncherPythonManifest) (*PythonManager, error) {
+	pythonManager := &PythonManager{
+		launcherManifest: launcherManifest,
+		bSettings:        bs,
+	}
+
+	manifest, err := GetOrCached[PythonManifest](
+		bs,
+		// The profiles share the runtimes folder but not always the manifest
+		filepath.Join(bs.RuntimePath, ".cache", "python_manifest_"+GetUrlCacheKey(launcherManifest.ManifestURL)+".json"),
+		launcherManifest.ManifestURL,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	pythonManager.cachedManifest = manifest
+
+	distributions, ok := (*manifest)[launcherManifest.Version]
+	if !ok {
+		return nil, fmt.Errorf("%w: %v", ErrNoPythonVersion, launcherManifest.Version)
+	}
+
+	env := GetRuleEnvironment(bs)
+	found := false
+	for _, d := range distributions {
+		if EvaluateRules(d.Rules, env) {
+			pythonManager.distribution = d
+			found = true
+			break
+		}
+	}
+
+	if !found {
+		return nil, ErrNoPythonForOs
+	}
+
+	if _, _, err := getArchiveEntryPath(pythonManager.GetPath(), pythonManager.distribution.Executable); err != nil || len(pythonManager.distribution.Executable) == 0 {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidExecutable, pythonManager.distribution.Executable)
+	}
+
+	// The python manifest isn't validated like the launcher one, the hash names the archive in the cache
+	if !IsSha256(pythonManager.distribution.Hash) {
+		return nil, fmt.Errorf("%w: expected the sha256 of the archive, got %q", ErrInvalidPython, pythonManager.distribution.Hash)
+	}
+
+	bs.Audit.Manifest("python", launcherManifest.Version)
+
+	return pythonManager, nil
+}
+
+// The distributions are shared between the profiles, like the java runtimes
+func (m *PythonManager) GetPath() string {
+	return filepath.Join(m.bSettings.RuntimePath, "runtime", "python", m.launcherManifest.Version)
+}
+
+func (m *PythonManager) GetEnvPath() string {
+	return filepath.Join(m.bSettings.LauncherPath, PYTHON_ENV_DIR)
+}
+
+func (m *PythonManager) GetExecutable() string {
+	_, file, _ := getArchiveEntryPath(m.GetPath(), m.distribution.Executable)
+	return file
+}
+
+// The variables python needs to only see the environment
+func (m *PythonManager) GetEnvironment() map[string]string {
+	return map[string]string{
+		"PYTHONPATH":       m.GetEnvPath(),
+		"PYTHONNOUSERSITE": "1",
+		"PYTHONHOME":       "",
+	}
+}
+
+func (m *PythonManager) getDistributionArchivePath() string {
+	return filepath.Join(m.bSettings.RuntimePath, ".cache", "python", strings.ToLower(m.distribution.Hash))
+}
+
+// Tells which archive the distribution was extracted from, so that it is not pruned
+// while a profile still uses it
+func (m *PythonManager) getInstalledPath() string {
+	return m.GetPath() + ".json"
+}
+
+func (m *PythonManager) getWheelPath(w PythonWheel) string {
+	return filepath.Join(m.bSettings.LauncherPath, ".cache", "wheels", strings.ToLower(w.Hash))
+}
+
+func (m *PythonManager) GetApplicableWheels() []PythonWheel {
+	env := GetRuleEnvironment(m.bSettings)
+	wheels := []PythonWheel{}
+
+	for _, w := range m.launcherManifest.Wheels {
+		if EvaluateRules(w.Rules, env) {
+			wheels = append(wheels, w)
+		}
+	}
+
+	return wheels
+}
+
+// Returns a list of files to download, the distribution and the wheels are installed
+// from them by InstallPending
+func (m *PythonManager) ValidateInstallation() ([]Downloadable, error) {
+	filesToDownload := []Downloadable{}
+	m.pendingDistribution = false
+	m.pendingWheels = nil
+
+	if _, valid := CheckExtractedArchive(m.getDistributionArchivePath()+".json", m.GetPath()); !valid {
+		m.pendingDistribution = true
+
+		archive := m.getDistributionArchivePath()
+		if GetHash(archive) != strings.ToLower(m.distribution.Hash) {
+			filesToDownload = append(filesToDownload, Downloadable{
+				Url:    m.distribution.Url,
+				Path:   archive,
+				Sha256: m.distribution.Hash,
+				Size:   m.distribution.Size,
+			})
+		}
+	}
+
+	for _, w := range m.GetApplicableWheels() {
+		if _, valid := CheckExtractedArchive(m.getWheelPath(w)+".json", m.GetEnvPath()); valid {
+			continue
+		}
+
+		m.pendingWheels = append(m.pendingWheels, w)
+
+		wheel := m.getWheelPath(w)
+		if GetHash(wheel) == strings.ToLower(w.Hash) {
+			continue
+		}
+
+		filesToDownload = append(filesToDownload, Downloadable{
+			Url:    w.Url,
+			Path:   wheel,
+			Sha256: w.Hash,
+		})
+	}
+
+	return filesToDownload, nil
+}
+
+// Extracts the distribution and the wheels that were missing or modified, once downloaded
+func (m *PythonManager) InstallPending() error {
+	if m.pendingDistribution {
+		if err := m.installDistribution(); err != nil {
+			return err
+		}
+
+		m.pendingDistribution = false
+	}
+
+	err := SaveToCache(m.getInstalledPath(), InstalledPython{
+		Version: m.launcherManifest.Version,
+		Archive: strings.ToLower(m.distribution.Hash),
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := m.pruneDistributions(); err != nil {
+		return err
+	}
+
+	// Wheels are zip files meant to be unpacked as is in site-packages
+	for _, w := range m.pendingWheels {
+		if _, err := InstallArchive(m.bSettings, m.getWheelPath(w), w.Hash, ArchiveFormatZip, m.GetEnvPath()); err != nil {
+			return err
+		}
+	}
+
+	m.pendingWheels = nil
+
+	return m.pruneEnvironment()
+}
+
+// The distribution is shared by the profiles, one of them may be running from it:
+// it is extracted next to its folder then swapped with it, and another build of
+// the same version is never mixed with this one
+func (m *PythonManager) installDistribution() error {
+	archive := m.getDistributionArchivePath()
+	if GetHash(archive) != strings.ToLower(m.distribution.Hash) {
+		return fmt.Errorf("%w: %v", ErrHashMismatch, archive)
+	}
+
+	target := m.GetPath()
+	if err := os.MkdirAll(filepath.Dir(target), DIR_MODE); err != nil {
+		return err
+	}
+
+	extracted, err := os.MkdirTemp(filepath.Dir(target), "."+filepath.Base(target)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(extracted)
+
+	index, err := ExtractArchive(archive, GetArchiveFormat(m.distribution.Format, m.distribution.Url), extracted)
+	if err != nil {
+		return fmt.Errorf("failed to extract %v: %w", archive, err)
+	}
+
+	previous := extracted + ".old"
+	if err := os.Rename(target, previous); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to replace %v, is it still in use? %w", target, err)
+	}
+	defer os.RemoveAll(previous)
+
+	if err := os.Rename(extracted, target); err != nil {
+		return err
+	}
+
+	for rel := range index.Files {
+		m.bSettings.Audit.FileDownloaded(Downloadable{Path: filepath.Join(target, filepath.FromSlash(rel))}, 0)
+	}
+
+	return SaveToCache(archive+".json", index)
+}
+
+// Removes the files of the wheels that are no longer used, and the wheels themselves
+func (m *PythonManager) pruneEnvironment() error {
+	used := map[string]bool{}
+	fileList := map[string]bool{}
+
+	for _, w := range m.GetApplicableWheels() {
+		used[strings.ToLower(w.Hash)] = true
+
+		index, err := LoadFromCache[ArchiveIndex](m.getWheelPath(w) + ".json")
+		if err != nil || index == nil {
+			continue
+		}
+
+		for rel := range index.Files {
+			fileList[filepath.Join(m.GetEnvPath(), filepath.FromSlash(rel))] = true
+		}
+
+		for rel := range index.Links {
+			fileList[filepath.Join(m.GetEnvPath(), filepath.FromSlash(rel))] = true
+		}
+	}
+
+	err := filepath.WalkDir(m.GetEnvPath(), func(currPath string, d fs.DirEntry, err error) error {
+		if os.IsNotExist(err) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		// Compiled by python itself
+		if d.IsDir() && d.Name() == "__pycache__" {
+			return filepath.SkipDir
+		}
+
+		if d.IsDir() || fileList[currPath] {
+			return nil
+		}
+
+		fmt.Printf("File / dir %v should not exist. Removing it.\n", currPath)
+		m.bSettings.Audit.FileRemoved(currPath)
+
+		return os.RemoveAll(currPath)
+	})
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Join(m.bSettings.LauncherPath, ".cache", "wheels")
+	entries, err := os.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	for _, e := range entries {
+		if !used[strings.TrimSuffix(e.Name(), ".json")] {
+			if err := os.RemoveAll(filepath.Join(dir, e.Name())); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Removes the archives of the distributions that are no longer installed
+func (m *PythonManager) pruneDistributions() error {
+	used := map[string]bool{strings.ToLower(m.distribution.Hash): true}
+
+	records, err := filepath.Glob(filepath.Join(m.bSettings.RuntimePath, "runtime", "python", "*.json"))
+	if err != nil {
+		return err
+	}
+
+	for _, record := range records {
+		if installed, err := LoadFromCache[InstalledPython](record); err == nil && installed != nil && len(installed.Archive) > 0 {
+			used[installed.Archive] = true
+		}
+	}
+
+	dir := filepath.Join(m.bSettings.RuntimePath, ".cache", "python")
+	entries, err := os.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	for _, e := range entries {
+		if !used[strings.TrimSuffix(e.Name(), ".json")] {
+			if err := os.RemoveAll(filepath.Join(dir, e.Name())); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
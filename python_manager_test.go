@@ -0,0 +1,96 @@
+/**
+ * Spectrum-Bootstrap - A bootstrap for Minecraft launchers
+ * Copyright (C) 2023-2024 - Oxodao
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ **/
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPythonInstallDistribution(t *testing.T) {
+	bs := &BootstrapSettings{RuntimePath: t.TempDir(), LauncherPath: t.TempDir()}
+
+	archive := writeTestTarGz(t, []testArchiveEntry{{name: "python/bin/python3", body: "new build"}})
+	m := &PythonManager{
+		bSettings:        bs,
+		launcherManifest: LauncherPythonManifest{Version: "3.12"},
+		distribution:     PythonDistribution{Hash: GetHash(archive), Format: ArchiveFormatTarGz, Executable: "python/bin/python3"},
+	}
+
+	cache := filepath.Join(bs.RuntimePath, ".cache", "python")
+	if err := os.MkdirAll(cache, DIR_MODE); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(m.getDistributionArchivePath(), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Another build of the same version, and the archive of a version no longer installed
+	stale := filepath.Join(m.GetPath(), "python", "lib", "old.py")
+	if err := os.MkdirAll(filepath.Dir(stale), DIR_MODE); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(stale, []byte("old build"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	unused := filepath.Join(cache, "0000000000000000000000000000000000000000000000000000000000000000")
+	if err := os.WriteFile(unused, []byte("unused"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	m.pendingDistribution = true
+	if err := m.InstallPending(); err != nil {
+		t.Fatal(err)
+	}
+
+	if body, err := os.ReadFile(m.GetExecutable()); err != nil || string(body) != "new build" {
+		t.Errorf("expected the new build to be installed, got %q (%v)", body, err)
+	}
+
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Errorf("expected the files of the other build to be removed")
+	}
+
+	entries, err := os.ReadDir(filepath.Dir(m.GetPath()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the version folder and its record
+	if len(entries) != 2 {
+		t.Errorf("expected no leftover of the extraction, got %v", entries)
+	}
+
+	if _, err := os.Stat(unused); !os.IsNotExist(err) {
+		t.Errorf("expected the unused archive to be pruned")
+	}
+
+	if _, valid := CheckExtractedArchive(m.getDistributionArchivePath()+".json", m.GetPath()); !valid {
+		t.Errorf("expected the installed build to be valid")
+	}
+}
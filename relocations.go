@@ -0,0 +1,168 @@
+/**
+ * Spectrum-Bootstrap - A bootstrap for Minecraft launchers
+ * Copyright (C) 2023-2024 - Oxodao
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ **/
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"path/filepath"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+const RELOCATIONS_FILENAME = "relocations.json"
+
+// How many times a document can move before we stop following it
+const MAX_RELOCATIONS = 5
+
+var ErrInvalidRelocation = errors.New("invalid relocation")
+
+// Documents that moved for good, old url => new url
+// They are only remembered when the document at the new url is signed by one of
+// the public keys, otherwise anyone able to answer once in place of our server
+// could send the players anywhere for every following run
+func GetRelocationsPath(bs *BootstrapSettings) string {
+	return filepath.Join(bs.LauncherPath, RELOCATIONS_FILENAME)
+}
+
+func LoadRelocations(bs *BootstrapSettings) (map[string]string, error) {
+	relocations, err := LoadFromCache[map[string]string](GetRelocationsPath(bs))
+	if err != nil || relocations == nil {
+		return map[string]string{}, err
+	}
+
+	return *relocations, nil
+}
+
+// Where the document at this url lives now
+func (bs *BootstrapSettings) GetRelocatedUrl(u string) string {
+	relocations, err := LoadRelocations(bs)
+	if err != nil {
+		fmt.Println("Failed to load the relocations:", err)
+		return u
+	}
+
+	for i := 0; i < MAX_RELOCATIONS; i++ {
+		next, ok := relocations[u]
+		if !ok {
+			break
+		}
+
+		u = next
+	}
+
+	return u
+}
+
+// The url a signed document says it is published at: the location of a launcher manifest,
+// the settings_url of the remote settings
+func getDocumentLocation(document any) string {
+	switch d := document.(type) {
+	case *LauncherManifest:
+		if d != nil {
+			return d.Location
+		}
+	case *map[string]json.RawMessage:
+		var location string
+		if d != nil && json.Unmarshal((*d)["settings_url"], &location) == nil {
+			return location
+		}
+	}
+
+	return ""
+}
+
+// The signature only covers the content of the document, not where it comes from nor when it was signed.
+// Someone answering in place of our server could redirect to an old signed document published elsewhere,
+// so the document must name the url it was found at, and must not be older than the one we had
+func checkRelocatedDocument(to string, document, cached any) error {
+	if location := getDocumentLocation(document); location != to {
+		return fmt.Errorf("%w: the document at %v says it is published at %q", ErrInvalidRelocation, to, location)
+	}
+
+	moved, ok := document.(*LauncherManifest)
+	previous, hasPrevious := cached.(*LauncherManifest)
+	if !ok || !hasPrevious || previous == nil {
+		return nil
+	}
+
+	// Like the rollbacks, only semver versions can be told apart
+	movedVersion, err := semver.NewVersion(moved.Version)
+	if err != nil {
+		return nil
+	}
+
+	if previousVersion, err := semver.NewVersion(previous.Version); err == nil && movedVersion.LessThan(previousVersion) {
+		return fmt.Errorf("%w: the manifest at %v is %v, older than %v", ErrInvalidRelocation, to, moved.Version, previous.Version)
+	}
+
+	return nil
+}
+
+// Remembers that the document at from is now at to, document is the one found at to
+// and cached the one we had before, see checkRelocatedDocument
+func (bs *BootstrapSettings) SaveRelocation(from, to string, document, cached any) error {
+	if from == to {
+		return nil
+	}
+
+	if err := checkRelocatedDocument(to, document, cached); err != nil {
+		return err
+	}
+
+	fromUrl, err := url.Parse(from)
+	if err != nil {
+		return err
+	}
+
+	toUrl, err := url.Parse(to)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidRelocation, err)
+	}
+
+	if toUrl.Scheme != "https" && (toUrl.Scheme != "http" || fromUrl.Scheme != "http") {
+		return fmt.Errorf("%w: %v is not https", ErrInvalidRelocation, to)
+	}
+
+	relocations, err := LoadRelocations(bs)
+	if err != nil {
+		return err
+	}
+
+	relocations[from] = to
+
+	// Pointing back to a previous location would make us go around in circles
+	u := to
+	for i := 0; i < MAX_RELOCATIONS; i++ {
+		next, ok := relocations[u]
+		if !ok {
+			break
+		}
+
+		if next == from {
+			return fmt.Errorf("%w: %v and %v point to each other", ErrInvalidRelocation, from, to)
+		}
+
+		u = next
+	}
+
+	return SaveToCache(GetRelocationsPath(bs), relocations)
+}
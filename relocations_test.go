@@ -0,0 +1,103 @@
+/**
+ * Spectrum-Bootstrap - A bootstrap for Minecraft launchers
+ * Copyright (C) 2023-2024 - Oxodao
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ **/
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestSaveRelocation(t *testing.T) {
+	manifest := func(location, version string) *LauncherManifest {
+		return &LauncherManifest{Location: location, Version: version}
+	}
+
+	settings := func(url string) *map[string]json.RawMessage {
+		raw, _ := json.Marshal(url)
+		return &map[string]json.RawMessage{"settings_url": raw}
+	}
+
+	const from = "https://mc.example.com/launcher_manifest.json"
+	const to = "https://cdn.example.org/launcher_manifest.json"
+
+	tests := []struct {
+		name     string
+		from     string
+		to       string
+		document any
+		cached   any
+		err      error
+	}{
+		{"manifest naming its url", from, to, manifest(to, "1.2.0"), manifest("", "1.1.0"), nil},
+		{"no previous manifest", from, to, manifest(to, "1.0.0"), nil, nil},
+		{"same version", from, to, manifest(to, "1.1.0"), manifest("", "1.1.0"), nil},
+		{"versions that are not semver", from, to, manifest(to, "beta"), manifest("", "1.1.0"), nil},
+		{"manifest without location", from, to, manifest("", "1.2.0"), nil, ErrInvalidRelocation},
+		{"manifest of another url", from, to, manifest("https://evil.example.net/launcher_manifest.json", "1.2.0"), nil, ErrInvalidRelocation},
+		{"older manifest", from, to, manifest(to, "1.0.0"), manifest("", "1.1.0"), ErrInvalidRelocation},
+		{"remote settings naming their url", "https://mc.example.com/settings.json", "https://cdn.example.org/settings.json", settings("https://cdn.example.org/settings.json"), nil, nil},
+		{"remote settings of another url", "https://mc.example.com/settings.json", "https://cdn.example.org/settings.json", settings("https://mc.example.com/settings.json"), nil, ErrInvalidRelocation},
+		{"from https to http", from, "http://cdn.example.org/launcher_manifest.json", manifest("http://cdn.example.org/launcher_manifest.json", "1.2.0"), nil, ErrInvalidRelocation},
+		{"from http to http", "http://mc.example.com/launcher_manifest.json", "http://cdn.example.org/launcher_manifest.json", manifest("http://cdn.example.org/launcher_manifest.json", "1.2.0"), nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bs := &BootstrapSettings{LauncherPath: t.TempDir()}
+
+			err := bs.SaveRelocation(tt.from, tt.to, tt.document, tt.cached)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("expected the error %v, got %v", tt.err, err)
+			}
+
+			expected := tt.to
+			if tt.err != nil {
+				expected = tt.from
+			}
+
+			if relocated := bs.GetRelocatedUrl(tt.from); relocated != expected {
+				t.Errorf("expected %v, got %v", expected, relocated)
+			}
+		})
+	}
+}
+
+func TestGetRelocatedUrl(t *testing.T) {
+	bs := &BootstrapSettings{LauncherPath: t.TempDir()}
+
+	a, b, c := "https://a.example.com/m.json", "https://b.example.com/m.json", "https://c.example.com/m.json"
+
+	if err := bs.SaveRelocation(a, b, &LauncherManifest{Location: b}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := bs.SaveRelocation(b, c, &LauncherManifest{Location: c}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if relocated := bs.GetRelocatedUrl(a); relocated != c {
+		t.Errorf("expected the moves to be followed to %v, got %v", c, relocated)
+	}
+
+	// Going back would make the documents point to each other
+	if err := bs.SaveRelocation(c, a, &LauncherManifest{Location: a}, nil); !errors.Is(err, ErrInvalidRelocation) {
+		t.Errorf("expected the loop to be refused, got %v", err)
+	}
+}
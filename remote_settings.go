@@ -0,0 +1,73 @@
+/**
+ * Spectrum-Bootstrap - A bootstrap for Minecraft launchers
+ * Copyright (C) 2023-2024 - Oxodao
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ **/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+)
+
+const REMOTE_SETTINGS_CACHE = "remote_settings.json"
+
+// Keys that the remote settings can't change:
+// - the folder name as the cache of the remote settings is stored in it
+// - the keys, otherwise anyone able to serve the remote settings could replace them
+var remoteSettingsForbiddenKeys = []string{
+	"launcher_foldername",
+	"public_keys",
+	"payload_public_keys",
+}
+
+// Fetches the remote settings, with the same rules as the launcher manifest:
+// - they are cached so that the bootstrap works offline
+// - they must be signed when public keys are configured
+//
+// The remote settings can set their own settings_url, which is then used for the next runs
+// so that we can move them to another domain
+func FetchRemoteSettings(bs *BootstrapSettings) (*SettingsLayer, error) {
+	cachePath := filepath.Join(bs.LauncherPath, ".cache", REMOTE_SETTINGS_CACHE)
+
+	url := bs.SettingsURL
+	cached, err := LoadFromCache[map[string]json.RawMessage](cachePath)
+	if err != nil {
+		return nil, err
+	}
+
+	if cached != nil {
+		var cachedUrl string
+		if raw, ok := (*cached)["settings_url"]; ok && json.Unmarshal(raw, &cachedUrl) == nil && len(cachedUrl) > 0 {
+			url = cachedUrl
+		}
+	}
+
+	values, err := GetOrCachedSigned[map[string]json.RawMessage](bs, cachePath, url)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, k := range remoteSettingsForbiddenKeys {
+		if _, ok := (*values)[k]; ok {
+			fmt.Printf("The remote settings can't change %v, ignoring it.\n", k)
+			delete(*values, k)
+		}
+	}
+
+	return &SettingsLayer{Name: "remote (" + url + ")", Values: *values}, nil
+}
@@ -0,0 +1,73 @@
+/**
+ * Spectrum-Bootstrap - A bootstrap for Minecraft launchers
+ * Copyright (C) 2023-2024 - Oxodao
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ **/
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFetchRemoteSettings(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/settings.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{
+			"launcher_brand": "Moved",
+			"launcher_foldername": "elsewhere",
+			"public_keys": ["attacker"],
+			"payload_public_keys": ["attacker"],
+			"settings_url": "` + "http://" + r.Host + `/moved.json"
+		}`))
+	})
+	mux.HandleFunc("/moved.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"launcher_brand": "New home"}`))
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	bs := &BootstrapSettings{LauncherPath: t.TempDir(), SettingsURL: server.URL + "/settings.json"}
+
+	layer, err := FetchRemoteSettings(bs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, k := range remoteSettingsForbiddenKeys {
+		if _, ok := layer.Values[k]; ok {
+			t.Errorf("expected %v to be removed", k)
+		}
+	}
+
+	var brand string
+	if err := json.Unmarshal(layer.Values["launcher_brand"], &brand); err != nil || brand != "Moved" {
+		t.Errorf("expected the brand to be kept, got %v (%v)", brand, err)
+	}
+
+	// The settings_url they set is used from the next run on
+	layer, err = FetchRemoteSettings(bs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := json.Unmarshal(layer.Values["launcher_brand"], &brand); err != nil || brand != "New home" {
+		t.Errorf("expected the moved settings, got %v (%v)", brand, err)
+	}
+}
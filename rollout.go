@@ -0,0 +1,93 @@
+/**
+ * Spectrum-Bootstrap - A bootstrap for Minecraft launchers
+ * Copyright (C) 2023-2024 - Oxodao
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ **/
+
+package main
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"hash/fnv"
+	"path/filepath"
+)
+
+const INSTALLATION_FILENAME = "installation.json"
+
+// Number of buckets an installation can fall in
+// A rollout progress is the amount of buckets receiving the release, i.e. a percentage
+const ROLLOUT_BUCKETS = 100
+
+// Loads the installation infos or creates them on the first run
+// The rollout bucket is picked at random once and never changes afterward
+func GetInstallation(bs *BootstrapSettings) (*Installation, error) {
+	path := filepath.Join(bs.LauncherPath, INSTALLATION_FILENAME)
+
+	installation, err := LoadFromCache[Installation](path)
+	if err != nil {
+		return nil, err
+	}
+
+	if installation != nil && installation.RolloutBucket >= 0 && installation.RolloutBucket < ROLLOUT_BUCKETS {
+		return installation, nil
+	}
+
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, err
+	}
+
+	installation = &Installation{
+		RolloutBucket: int(binary.BigEndian.Uint64(buf) % ROLLOUT_BUCKETS),
+	}
+
+	return installation, SaveToCache(path, installation)
+}
+
+// The bucket of this installation for the given rollout group
+// Each group shifts the buckets so that the same installations
+// are not always the first ones to get new releases
+func (i *Installation) GetBucket(group int) int {
+	h := fnv.New32a()
+	binary.Write(h, binary.BigEndian, int64(group))
+
+	return (i.RolloutBucket + int(h.Sum32()%ROLLOUT_BUCKETS)) % ROLLOUT_BUCKETS
+}
+
+// A release without rollout rules is available to everyone
+func (i *Installation) IsInRollout(r *Rollout) bool {
+	if r == nil || r.Progress >= ROLLOUT_BUCKETS {
+		return true
+	}
+
+	return i.GetBucket(r.Group) < r.Progress
+}
+
+// Picks the first java version this installation is part of the rollout
+// If none is available for us, the last one is used as it should be the oldest
+func (i *Installation) SelectJavaVersion(versions []MainJavaManifestVersion) (MainJavaManifestVersion, bool) {
+	if len(versions) == 0 {
+		return MainJavaManifestVersion{}, false
+	}
+
+	for _, v := range versions {
+		if i.IsInRollout(v.Availability) {
+			return v, true
+		}
+	}
+
+	return versions[len(versions)-1], true
+}
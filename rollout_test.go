@@ -0,0 +1,144 @@
+/**
+ * Spectrum-Bootstrap - A bootstrap for Minecraft launchers
+ * Copyright (C) 2023-2024 - Oxodao
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ **/
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGetInstallation(t *testing.T) {
+	bs := &BootstrapSettings{LauncherPath: t.TempDir()}
+
+	installation, err := GetInstallation(bs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if installation.RolloutBucket < 0 || installation.RolloutBucket >= ROLLOUT_BUCKETS {
+		t.Fatalf("expected a bucket between 0 and %v, got %v", ROLLOUT_BUCKETS, installation.RolloutBucket)
+	}
+
+	again, err := GetInstallation(bs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if again.RolloutBucket != installation.RolloutBucket {
+		t.Errorf("expected the bucket %v to be kept, got %v", installation.RolloutBucket, again.RolloutBucket)
+	}
+
+	// A bucket out of range is picked again
+	path := filepath.Join(bs.LauncherPath, INSTALLATION_FILENAME)
+	if err := os.WriteFile(path, []byte(`{"rollout_bucket": 250}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	installation, err = GetInstallation(bs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if installation.RolloutBucket < 0 || installation.RolloutBucket >= ROLLOUT_BUCKETS {
+		t.Errorf("expected the bucket to be picked again, got %v", installation.RolloutBucket)
+	}
+}
+
+func TestIsInRollout(t *testing.T) {
+	tests := []struct {
+		name     string
+		rollout  *Rollout
+		expected int
+	}{
+		{"no rollout", nil, ROLLOUT_BUCKETS},
+		{"full rollout", &Rollout{Progress: ROLLOUT_BUCKETS}, ROLLOUT_BUCKETS},
+		{"over a full rollout", &Rollout{Progress: 150}, ROLLOUT_BUCKETS},
+		{"not started", &Rollout{Progress: 0}, 0},
+		{"negative progress", &Rollout{Progress: -5}, 0},
+		{"a quarter", &Rollout{Group: 1, Progress: 25}, 25},
+		{"a quarter of another group", &Rollout{Group: 7, Progress: 25}, 25},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Every bucket is shifted to a different one, the progress is the share of installations
+			count := 0
+			for bucket := 0; bucket < ROLLOUT_BUCKETS; bucket++ {
+				if (&Installation{RolloutBucket: bucket}).IsInRollout(tt.rollout) {
+					count++
+				}
+			}
+
+			if count != tt.expected {
+				t.Errorf("expected %v installations in the rollout, got %v", tt.expected, count)
+			}
+		})
+	}
+}
+
+func TestGetBucket(t *testing.T) {
+	installation := &Installation{RolloutBucket: 42}
+
+	if installation.GetBucket(3) != installation.GetBucket(3) {
+		t.Fatal("expected the same bucket for the same group")
+	}
+
+	// The groups don't all shift the buckets the same way
+	buckets := map[int]bool{}
+	for group := 0; group < 10; group++ {
+		buckets[installation.GetBucket(group)] = true
+	}
+
+	if len(buckets) < 2 {
+		t.Errorf("expected the groups to give different buckets, got %v", buckets)
+	}
+}
+
+func TestSelectJavaVersion(t *testing.T) {
+	version := func(name string, rollout *Rollout) MainJavaManifestVersion {
+		v := MainJavaManifestVersion{Availability: rollout}
+		v.Version.Name = name
+
+		return v
+	}
+
+	installation := &Installation{RolloutBucket: 10}
+
+	tests := []struct {
+		name     string
+		versions []MainJavaManifestVersion
+		expected string
+		ok       bool
+	}{
+		{"no version", nil, "", false},
+		{"available", []MainJavaManifestVersion{version("17.0.9", nil), version("17.0.8", nil)}, "17.0.9", true},
+		{"not rolled out yet", []MainJavaManifestVersion{version("17.0.9", &Rollout{Progress: 0}), version("17.0.8", nil)}, "17.0.8", true},
+		{"none rolled out", []MainJavaManifestVersion{version("17.0.9", &Rollout{Progress: 0}), version("17.0.8", &Rollout{Progress: 0})}, "17.0.8", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, ok := installation.SelectJavaVersion(tt.versions)
+			if ok != tt.ok || v.Version.Name != tt.expected {
+				t.Errorf("expected %q (%v), got %q (%v)", tt.expected, tt.ok, v.Version.Name, ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,174 @@
+/**
+ * Spectrum-Bootstrap - A bootstrap for Minecraft launchers
+ * Copyright (C) 2023-2024 - Oxodao
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ **/
+
+package main
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Rules work the same way as Mojang's ones:
+// - Without rules, the element always applies
+// - Otherwise, it's disallowed by default and the last matching rule decides
+const (
+	RuleActionAllow    = "allow"
+	RuleActionDisallow = "disallow"
+)
+
+const FeatureIsPortable = "is_portable"
+
+type RuleEnvironment struct {
+	Os        string
+	Arch      string
+	OsVersion string
+	Features  map[string]bool
+}
+
+var (
+	osVersion     string
+	osVersionOnce sync.Once
+)
+
+// The os version is expensive to get on some systems, we only want to do it once
+func GetCachedOsVersion() string {
+	osVersionOnce.Do(func() {
+		var err error
+
+		osVersion, err = GetOsVersion()
+		if err != nil {
+			fmt.Println("Failed to get the os version:", err)
+		}
+	})
+
+	return osVersion
+}
+
+func GetRuleEnvironment(bs *BootstrapSettings) RuleEnvironment {
+	features := map[string]bool{
+		FeatureIsPortable: bs.Portable,
+	}
+
+	for k, v := range bs.Features {
+		features[k] = v
+	}
+
+	platform := bs.GetPlatform()
+
+	return RuleEnvironment{
+		Os:        normalizeRuleOs(platform.Os),
+		Arch:      normalizeRuleArch(platform.Arch),
+		OsVersion: GetCachedOsVersion(),
+		Features:  features,
+	}
+}
+
+// Mojang uses the java names, we also accept the go ones
+func normalizeRuleOs(os string) string {
+	switch strings.ToLower(os) {
+	case "darwin", "mac-os", "macos":
+		return "osx"
+	default:
+		return strings.ToLower(os)
+	}
+}
+
+func normalizeRuleArch(arch string) string {
+	switch strings.ToLower(arch) {
+	case "amd64", "x64", "amd64p32":
+		return "x86_64"
+	case "386", "i386", "i686":
+		return "x86"
+	case "aarch64":
+		return "arm64"
+	default:
+		return strings.ToLower(arch)
+	}
+}
+
+func (r Rule) Matches(env RuleEnvironment) bool {
+	if r.Os != nil {
+		if len(r.Os.Name) > 0 && normalizeRuleOs(r.Os.Name) != env.Os {
+			return false
+		}
+
+		if len(r.Os.Arch) > 0 && normalizeRuleArch(r.Os.Arch) != env.Arch {
+			return false
+		}
+
+		if len(r.Os.Version) > 0 {
+			matched, err := regexp.MatchString(r.Os.Version, env.OsVersion)
+			if err != nil {
+				fmt.Printf("Invalid os version rule %v: %v\n", r.Os.Version, err)
+				return false
+			}
+
+			if !matched {
+				return false
+			}
+		}
+	}
+
+	for feature, expected := range r.Features {
+		if env.Features[feature] != expected {
+			return false
+		}
+	}
+
+	return true
+}
+
+func EvaluateRules(rules []Rule, env RuleEnvironment) bool {
+	if len(rules) == 0 {
+		return true
+	}
+
+	allowed := false
+	for _, r := range rules {
+		if r.Matches(env) {
+			allowed = r.Action == RuleActionAllow
+		}
+	}
+
+	return allowed
+}
+
+// Preserve patterns are matched against the slash-separated path relative to the launcher folder
+// A pattern ending with /** matches everything inside that folder
+func IsPreserved(patterns []string, relPath string) bool {
+	relPath = path.Clean(strings.ReplaceAll(relPath, "\\", "/"))
+
+	for _, p := range patterns {
+		if prefix, ok := strings.CutSuffix(p, "/**"); ok {
+			if relPath == prefix || strings.HasPrefix(relPath, prefix+"/") {
+				return true
+			}
+
+			continue
+		}
+
+		if matched, _ := path.Match(p, relPath); matched {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,312 @@
+/**
+ * Spectrum-Bootstrap - A bootstrap for Minecraft launchers
+ * Copyright (C) 2023-2024 - Oxodao
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ **/
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// The runtime archives are kept here once downloaded, named after their hash
+const RUNTIME_ARCHIVES_CACHE_DIR = "runtimes"
+
+const (
+	RuntimeSourceManifest = "manifest"
+	RuntimeSourceArchive  = "archive"
+)
+
+var RuntimeSources = []string{RuntimeSourceManifest, RuntimeSourceArchive}
+
+var ErrNoJavaInArchive = errors.New("no java found in the archive")
+
+func (r LauncherRuntime) GetSource() string {
+	if len(r.Source) == 0 {
+		return RuntimeSourceManifest
+	}
+
+	return r.Source
+}
+
+// The first archive whose rules match is used
+func GetArchiveJvmManager(bs *BootstrapSettings, os string, launcherRuntime LauncherRuntime) (*JvmManager, error) {
+	env := GetRuleEnvironment(bs)
+
+	var archive *RuntimeArchive
+	for i, a := range launcherRuntime.Archives {
+		if EvaluateRules(a.Rules, env) {
+			archive = &launcherRuntime.Archives[i]
+			break
+		}
+	}
+
+	if archive == nil {
+		return nil, ErrNoJavaForOs
+	}
+
+	jvmManager := &JvmManager{
+		runtime:   launcherRuntime,
+		bSettings: bs,
+		os:        os,
+		archive:   archive,
+	}
+
+	version := archive.Version
+	if len(version) == 0 {
+		version = strings.ToLower(archive.Hash)
+	}
+	jvmManager.version.Version.Name = version
+
+	if installed := jvmManager.GetInstalled(); installed != nil && installed.Version != version {
+		fmt.Printf("Updating %v from %v to %v.\n", launcherRuntime.Component, installed.Version, version)
+	}
+
+	bs.Audit.Manifest("java/"+launcherRuntime.Component, version)
+
+	return jvmManager, nil
+}
+
+func (m *JvmManager) getRuntimeArchivePath() string {
+	return filepath.Join(m.bSettings.RuntimePath, ".cache", RUNTIME_ARCHIVES_CACHE_DIR, strings.ToLower(m.archive.Hash))
+}
+
+func (m *JvmManager) validateArchiveInstallation() ([]Downloadable, error) {
+	bp := m.GetPath()
+	archive := m.getRuntimeArchivePath()
+
+	fileList, valid := CheckExtractedArchive(archive+".json", bp)
+	if !valid {
+		m.pendingArchive = true
+
+		if GetHash(archive) == strings.ToLower(m.archive.Hash) {
+			return []Downloadable{}, nil
+		}
+
+		return []Downloadable{{
+			Url:    m.archive.Url,
+			Path:   archive,
+			Sha256: m.archive.Hash,
+			Size:   m.archive.Size,
+		}}, nil
+	}
+
+	// Removing the files that should not exist
+	err := filepath.WalkDir(bp, func(currPath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() || slices.Contains(fileList, currPath) {
+			return nil
+		}
+
+		fmt.Printf("File / dir %v should not exist. Removing it.\n", currPath)
+		m.bSettings.Audit.FileRemoved(currPath)
+
+		return os.RemoveAll(currPath)
+	})
+
+	return []Downloadable{}, err
+}
+
+// Removes the archives that no installed runtime was extracted from
+// The profiles share the runtimes folder, the archives of their runtimes are kept too
+func (m *RuntimeManager) pruneArchives() error {
+	used := map[string]bool{}
+	for _, jvm := range m.runtimes {
+		if jvm.archive != nil {
+			used[strings.ToLower(jvm.archive.Hash)] = true
+		}
+	}
+
+	records, err := filepath.Glob(filepath.Join(m.bSettings.RuntimePath, "runtime", "*", "*.json"))
+	if err != nil {
+		return err
+	}
+
+	for _, record := range records {
+		if installed, err := LoadFromCache[InstalledRuntime](record); err == nil && installed != nil && len(installed.Archive) > 0 {
+			used[installed.Archive] = true
+		}
+	}
+
+	dir := filepath.Join(m.bSettings.RuntimePath, ".cache", RUNTIME_ARCHIVES_CACHE_DIR)
+	entries, err := os.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	for _, e := range entries {
+		if !used[strings.TrimSuffix(e.Name(), ".json")] {
+			if err := os.RemoveAll(filepath.Join(dir, e.Name())); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Vendors put the java in a folder named after the build, and in a bundle on macOS
+func findJavaHome(dir string) (string, error) {
+	candidates := []string{dir, filepath.Join(dir, "Contents", "Home")}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+
+	for _, e := range entries {
+		if e.IsDir() {
+			candidates = append(candidates, filepath.Join(dir, e.Name()), filepath.Join(dir, e.Name(), "Contents", "Home"))
+		}
+	}
+
+	for _, home := range candidates {
+		for _, name := range []string{"java", "java.exe"} {
+			if fi, err := os.Stat(filepath.Join(home, "bin", name)); err == nil && !fi.IsDir() {
+				return home, nil
+			}
+		}
+	}
+
+	return "", ErrNoJavaInArchive
+}
+
+// Moves the java found in the extracted archive to the layout of the downloaded runtimes,
+// see GetJavaExecutable
+func normalizeJavaLayout(platformOs, extracted, target string) error {
+	home, err := findJavaHome(extracted)
+	if err != nil {
+		return err
+	}
+
+	if err := os.RemoveAll(target); err != nil {
+		return err
+	}
+
+	if platformOs != "darwin" {
+		return os.Rename(home, target)
+	}
+
+	bundle := filepath.Join(target, "jre.bundle")
+	if filepath.Base(filepath.Dir(home)) == "Contents" {
+		if err := os.MkdirAll(target, DIR_MODE); err != nil {
+			return err
+		}
+
+		return os.Rename(filepath.Dir(filepath.Dir(home)), bundle)
+	}
+
+	if err := os.MkdirAll(filepath.Join(bundle, "Contents"), DIR_MODE); err != nil {
+		return err
+	}
+
+	return os.Rename(home, filepath.Join(bundle, "Contents", "Home"))
+}
+
+// Lists the files of the folder like an extracted archive, after they were moved around
+func indexFolder(root string) (*ArchiveIndex, error) {
+	index := &ArchiveIndex{Files: map[string]string{}, Links: map[string]string{}}
+
+	err := filepath.WalkDir(root, func(currPath string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		rel, err := filepath.Rel(root, currPath)
+		if err != nil {
+			return err
+		}
+
+		if d.Type()&fs.ModeSymlink != 0 {
+			target, err := os.Readlink(currPath)
+			if err != nil {
+				return err
+			}
+
+			index.Links[filepath.ToSlash(rel)] = filepath.ToSlash(target)
+			return nil
+		}
+
+		index.Files[filepath.ToSlash(rel)] = GetHash(currPath)
+
+		return nil
+	})
+
+	return index, err
+}
+
+// Extracts the downloaded archive, then checks that the java it contains starts
+func (m *JvmManager) InstallPending() error {
+	if !m.pendingArchive {
+		return nil
+	}
+
+	bp := m.GetPath()
+	archive := m.getRuntimeArchivePath()
+
+	if GetHash(archive) != strings.ToLower(m.archive.Hash) {
+		return fmt.Errorf("%w: %v", ErrHashMismatch, archive)
+	}
+
+	extracted := bp + ".tmp"
+	if err := os.RemoveAll(extracted); err != nil {
+		return err
+	}
+	defer os.RemoveAll(extracted)
+
+	if _, err := ExtractArchive(archive, GetArchiveFormat(m.archive.Format, m.archive.Url), extracted); err != nil {
+		return fmt.Errorf("failed to extract %v: %w", m.archive.Url, err)
+	}
+
+	if err := normalizeJavaLayout(m.bSettings.GetPlatform().Os, extracted, bp); err != nil {
+		return fmt.Errorf("failed to install %v: %w", m.archive.Url, err)
+	}
+
+	index, err := indexFolder(bp)
+	if err != nil {
+		return err
+	}
+
+	for rel := range index.Files {
+		m.bSettings.Audit.FileDownloaded(Downloadable{Path: filepath.Join(bp, filepath.FromSlash(rel))}, 0)
+	}
+
+	executable, err := m.GetJavaExecutable()
+	if err != nil {
+		return err
+	}
+
+	if _, err := ProbeJava(filepath.Dir(filepath.Dir(executable))); err != nil {
+		return fmt.Errorf("the java of %v doesn't start: %w", m.archive.Url, err)
+	}
+
+	if err := SaveToCache(archive+".json", index); err != nil {
+		return err
+	}
+
+	m.pendingArchive = false
+
+	return nil
+}
@@ -0,0 +1,215 @@
+/**
+ * Spectrum-Bootstrap - A bootstrap for Minecraft launchers
+ * Copyright (C) 2023-2024 - Oxodao
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ **/
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+)
+
+// Installs the java runtimes declared by the manifest
+// The first one starts the launcher, the others are given to it through the placeholders
+type RuntimeManager struct {
+	launcherManifest LauncherJavaManifest
+	bSettings        *BootstrapSettings
+
+	// The runtimes available on this computer, the optional ones may be missing
+	runtimes []*JvmManager
+}
+
+func GetRuntimeManager(bs *BootstrapSettings, cfg *UserConfig, launcherManifest LauncherJavaManifest) (*RuntimeManager, error) {
+	runtimeManager := &RuntimeManager{
+		launcherManifest: launcherManifest,
+		bSettings:        bs,
+	}
+
+	platformKeys, osErr := bs.GetPlatform().GetJavaOsKeys(bs.PlatformFallbacks)
+	os := ""
+	if osErr == nil {
+		os = platformKeys[0]
+	}
+
+	// Only loaded when one of the runtimes is downloaded
+	var mainManifest *MainJavaManifest
+	getMainManifest := func() (*MainJavaManifest, error) {
+		if mainManifest != nil {
+			return mainManifest, nil
+		}
+
+		var err error
+		mainManifest, err = GetOrCached[MainJavaManifest](
+			bs,
+			// The profiles share the runtimes folder but not always the manifest
+			filepath.Join(bs.RuntimePath, ".cache", "main_java_manifest_"+GetUrlCacheKey(launcherManifest.ManifestURL)+".json"),
+			launcherManifest.ManifestURL,
+		)
+
+		return mainManifest, err
+	}
+
+	for _, r := range launcherManifest.Runtimes {
+		if len(cfg.Java) > 0 && r.AllowsSystemJava() {
+			java, err := FindSystemJava(cfg, bs.GetPlatform(), r.Version)
+			if err == nil {
+				fmt.Printf("Using java %v from %v for the %v runtime.\n", java.Version, java.Home, r.Name)
+				bs.Audit.Manifest("java/"+r.Component, "system "+java.Version)
+
+				runtimeManager.runtimes = append(runtimeManager.runtimes, &JvmManager{
+					runtime:   r,
+					os:        os,
+					bSettings: bs,
+					system:    java,
+				})
+				continue
+			}
+
+			fmt.Printf("No installed java can be used for the %v runtime, downloading it: %v\n", r.Name, err)
+		}
+
+		var jvm *JvmManager
+		err := osErr
+		if err == nil && r.GetSource() == RuntimeSourceArchive {
+			jvm, err = GetArchiveJvmManager(bs, os, r)
+		} else if err == nil {
+			var manifest *MainJavaManifest
+			manifest, err = getMainManifest()
+			if err != nil {
+				return nil, err
+			}
+
+			jvm, err = GetJvmManager(bs, manifest, platformKeys, r)
+		}
+
+		if err != nil {
+			if r.Optional && (errors.Is(err, ErrFailedDetermineOs) || errors.Is(err, ErrNoJavaForOs) || errors.Is(err, ErrNoJavaVersionForOs) || errors.Is(err, ErrNoJavaVersionMatch)) {
+				fmt.Printf("Skipping the %v runtime: %v\n", r.Name, err)
+				continue
+			}
+
+			return nil, fmt.Errorf("%v runtime: %w", r.Name, err)
+		}
+
+		runtimeManager.runtimes = append(runtimeManager.runtimes, jvm)
+	}
+
+	return runtimeManager, nil
+}
+
+func (m *RuntimeManager) Get(name string) *JvmManager {
+	for _, jvm := range m.runtimes {
+		if jvm.runtime.Name == name {
+			return jvm
+		}
+	}
+
+	return nil
+}
+
+// The runtime starting the launcher
+func (m *RuntimeManager) GetMain() *JvmManager {
+	if m == nil || len(m.launcherManifest.Runtimes) == 0 {
+		return nil
+	}
+
+	return m.Get(m.launcherManifest.Runtimes[0].Name)
+}
+
+// Returns a list of files to re-download for all the runtimes
+func (m *RuntimeManager) ValidateInstallation() ([]Downloadable, error) {
+	filesToDownload := []Downloadable{}
+	validated := map[string]bool{}
+
+	for _, jvm := range m.runtimes {
+		// Two runtimes can use the same component, it must only be downloaded once
+		if validated[jvm.GetPath()] {
+			continue
+		}
+		validated[jvm.GetPath()] = true
+
+		files, err := jvm.ValidateInstallation()
+		if err != nil {
+			return nil, err
+		}
+
+		filesToDownload = append(filesToDownload, files...)
+	}
+
+	return filesToDownload, nil
+}
+
+// Installs the runtimes downloaded as archives
+func (m *RuntimeManager) InstallPending() error {
+	for _, jvm := range m.runtimes {
+		if err := jvm.InstallPending(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Records the builds that were installed, once their files are downloaded
+// The archives of the previous builds are then removed
+func (m *RuntimeManager) SaveInstalled() error {
+	for _, jvm := range m.runtimes {
+		if err := jvm.SaveInstalled(); err != nil {
+			return err
+		}
+	}
+
+	return m.pruneArchives()
+}
+
+// <name>RuntimePath and <name>JavaExecutable for each runtime, empty when it is not available
+// The main runtime is also runtimePath and javaExecutable
+func (m *RuntimeManager) GetVariables() (map[string]string, error) {
+	variables := map[string]string{
+		"osArch":         "",
+		"runtimePath":    "",
+		"javaExecutable": "",
+	}
+
+	if m == nil {
+		return variables, nil
+	}
+
+	for _, r := range m.launcherManifest.Runtimes {
+		variables[r.Name+"RuntimePath"] = ""
+		variables[r.Name+"JavaExecutable"] = ""
+	}
+
+	for _, jvm := range m.runtimes {
+		javaExecutable, err := jvm.GetJavaExecutable()
+		if err != nil {
+			return nil, err
+		}
+
+		variables[jvm.runtime.Name+"RuntimePath"] = jvm.GetPath()
+		variables[jvm.runtime.Name+"JavaExecutable"] = javaExecutable
+	}
+
+	if main := m.GetMain(); main != nil {
+		variables["osArch"] = main.os
+		variables["runtimePath"] = variables[main.runtime.Name+"RuntimePath"]
+		variables["javaExecutable"] = variables[main.runtime.Name+"JavaExecutable"]
+	}
+
+	return variables, nil
+}
@@ -0,0 +1,398 @@
+/**
+ * Spectrum-Bootstrap - A bootstrap for Minecraft launchers
+ * Copyright (C) 2023-2024 - Oxodao
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ **/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+)
+
+const (
+	SETTINGS_FILENAME   = "bs_settings.json"
+	SETTINGS_ENV_PREFIX = "BOOTSTRAP_"
+
+	// The name of the layer of the command line flags, as listed in the sources of the settings
+	SETTINGS_LAYER_COMMAND_LINE = "command line"
+)
+
+// The settings are made of several layers, each one overriding the keys it sets:
+// 1. The bs_settings.json embedded at build time, then the payload appended to the executable (see payload.go)
+// 2. The remote settings (see remote_settings.go)
+// 3. The system policy file, i.e. /etc/{foldername}/bs_settings.json
+// 4. The bs_settings.json next to the executable
+// 5. The settings of the selected profile (see profiles.go)
+// 6. The environment variables, i.e. BOOTSTRAP_LAUNCHER_MANIFEST
+// 7. The command line flags
+type SettingsLayer struct {
+	Name   string
+	Values map[string]json.RawMessage
+}
+
+// Flag that can be repeated: --setting key=value
+type SettingsOverrides []string
+
+func (o *SettingsOverrides) String() string {
+	return strings.Join(*o, ", ")
+}
+
+func (o *SettingsOverrides) Set(value string) error {
+	if !strings.Contains(value, "=") {
+		return fmt.Errorf("expected key=value, got %v", value)
+	}
+
+	*o = append(*o, value)
+
+	return nil
+}
+
+// The json type of each settings key, used to convert the plain values
+// of the environment and command line to json
+func getSettingsKinds() map[string]reflect.Kind {
+	kinds := map[string]reflect.Kind{}
+
+	t := reflect.TypeOf(BootstrapSettings{})
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if len(name) == 0 || name == "-" {
+			continue
+		}
+
+		kinds[name] = t.Field(i).Type.Kind()
+	}
+
+	return kinds
+}
+
+func toSettingsValue(kinds map[string]reflect.Kind, key, value string) (json.RawMessage, error) {
+	kind, ok := kinds[key]
+	if !ok {
+		return nil, fmt.Errorf("unknown setting %v", key)
+	}
+
+	if kind == reflect.String {
+		return json.Marshal(value)
+	}
+
+	if !json.Valid([]byte(value)) {
+		return nil, fmt.Errorf("setting %v expects a json value, got %v", key, value)
+	}
+
+	return json.RawMessage(value), nil
+}
+
+func readSettingsFile(name, path string) (*SettingsLayer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	} else if err != nil {
+		return nil, nil
+	}
+
+	layer := &SettingsLayer{Name: name + " (" + path + ")"}
+	if err := json.Unmarshal(data, &layer.Values); err != nil {
+		return nil, fmt.Errorf("failed to read %v: %w", path, err)
+	}
+
+	return layer, nil
+}
+
+func getEnvironmentSettingsLayer(kinds map[string]reflect.Kind) (*SettingsLayer, error) {
+	layer := &SettingsLayer{Name: "environment", Values: map[string]json.RawMessage{}}
+
+	for key := range kinds {
+		name := SETTINGS_ENV_PREFIX + strings.ToUpper(key)
+
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+
+		raw, err := toSettingsValue(kinds, key, value)
+		if err != nil {
+			return nil, fmt.Errorf("%v: %w", name, err)
+		}
+
+		layer.Values[key] = raw
+	}
+
+	return layer, nil
+}
+
+func getCommandLineSettingsLayer(kinds map[string]reflect.Kind) (*SettingsLayer, error) {
+	layer := &SettingsLayer{Name: SETTINGS_LAYER_COMMAND_LINE, Values: map[string]json.RawMessage{}}
+
+	overrides := append([]string{}, *settingsOverrides...)
+	if len(*manifestUrl) > 0 {
+		overrides = append(overrides, "launcher_manifest="+*manifestUrl)
+	}
+
+	if len(*brand) > 0 {
+		overrides = append(overrides, "launcher_brand="+*brand)
+	}
+
+	if len(*folderName) > 0 {
+		overrides = append(overrides, "launcher_foldername="+*folderName)
+	}
+
+	for _, o := range overrides {
+		key, value, _ := strings.Cut(o, "=")
+
+		raw, err := toSettingsValue(kinds, key, value)
+		if err != nil {
+			return nil, err
+		}
+
+		layer.Values[key] = raw
+	}
+
+	return layer, nil
+}
+
+// Merges every layer into the settings
+// Returns the name of the layer each key comes from
+// The remote layer and the profile are given by the caller as they can only be known
+// once the other layers are loaded
+func loadSettingsLayers(settings *BootstrapSettings, remote *SettingsLayer, profile string) (map[string]string, error) {
+	kinds := getSettingsKinds()
+	sources := map[string]string{}
+
+	apply := func(layer *SettingsLayer) error {
+		if layer == nil {
+			return nil
+		}
+
+		data, err := json.Marshal(layer.Values)
+		if err != nil {
+			return err
+		}
+
+		if err := json.Unmarshal(data, settings); err != nil {
+			return fmt.Errorf("invalid settings in %v: %w", layer.Name, err)
+		}
+
+		for key := range layer.Values {
+			sources[key] = layer.Name
+		}
+
+		return nil
+	}
+
+	embedded := &SettingsLayer{Name: "embedded"}
+	if err := json.Unmarshal(BOOTSTRAP_SETTINGS_STR, &embedded.Values); err != nil {
+		return nil, err
+	}
+
+	if err := apply(embedded); err != nil {
+		return nil, err
+	}
+
+	// Only the embedded settings can tell which keys are trusted to rebrand the bootstrap
+	payload, err := LoadOwnPayload(settings.PayloadPublicKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	if payload != nil {
+		layer := &SettingsLayer{Name: "payload"}
+		if len(payload.Settings) > 0 {
+			if err := json.Unmarshal(payload.Settings, &layer.Values); err != nil {
+				return nil, ErrPayloadCorrupted
+			}
+		}
+
+		if err := apply(layer); err != nil {
+			return nil, err
+		}
+
+		if len(payload.Icon) > 0 {
+			settings.Icon = payload.Icon
+		}
+	}
+
+	if err := apply(remote); err != nil {
+		return nil, err
+	}
+
+	// The folder name can't come from the policy file itself
+	policyPath := GetPolicySettingsPath(settings.FolderName)
+	if len(policyPath) > 0 {
+		policy, err := readSettingsFile("policy", policyPath)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := apply(policy); err != nil {
+			return nil, err
+		}
+	}
+
+	if executable, err := os.Executable(); err == nil {
+		local, err := readSettingsFile("executable folder", filepath.Join(filepath.Dir(executable), SETTINGS_FILENAME))
+		if err != nil {
+			return nil, err
+		}
+
+		if err := apply(local); err != nil {
+			return nil, err
+		}
+	}
+
+	environment, err := getEnvironmentSettingsLayer(kinds)
+	if err != nil {
+		return nil, err
+	}
+
+	commandLine, err := getCommandLineSettingsLayer(kinds)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := apply(environment); err != nil {
+		return nil, err
+	}
+
+	if err := apply(commandLine); err != nil {
+		return nil, err
+	}
+
+	// The profiles can be set by any layer, so we only know them now
+	// The environment and command line still win over the profile
+	if len(profile) > 0 {
+		p, err := settings.FindProfile(profile)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, layer := range []*SettingsLayer{p.GetSettingsLayer(), environment, commandLine} {
+			if err := apply(layer); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return sources, nil
+}
+
+// Loads the settings, prepares the launcher directory
+// and applies the preferences of the player
+func LoadBootstrapSettings(settings *BootstrapSettings, chooseProfile ProfileChooser) (*UserConfig, error) {
+	var err error
+
+	settings.Sources, err = loadSettingsLayers(settings, nil, "")
+	if err != nil {
+		return nil, err
+	}
+
+	if len(*basepath) > 0 {
+		settings.LauncherPath = *basepath
+		settings.Portable = true
+	}
+
+	settings.LauncherPath, err = GetLauncherDirectory(settings)
+	if err != nil {
+		return nil, err
+	}
+
+	// Re-applying every layer so that the local ones still win over the remote and profile ones
+	reload := func(remote *SettingsLayer, profile string) error {
+		*settings = BootstrapSettings{
+			LauncherPath: settings.LauncherPath,
+			Portable:     settings.Portable,
+		}
+
+		settings.Sources, err = loadSettingsLayers(settings, remote, profile)
+
+		return err
+	}
+
+	var remote *SettingsLayer
+	if len(settings.SettingsURL) > 0 {
+		remote, err = FetchRemoteSettings(settings)
+		if err != nil {
+			// The remote settings are a refresh of the local ones, we can live without them
+			fmt.Println("Failed to fetch the remote settings:")
+			fmt.Println(err)
+			remote = nil
+		} else if err := reload(remote, ""); err != nil {
+			return nil, err
+		}
+	}
+
+	profile, err := settings.SelectProfile(*profileName, chooseProfile)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(profile) > 0 {
+		if err := reload(remote, profile); err != nil {
+			return nil, err
+		}
+	}
+
+	err = settings.UseProfile(profile)
+	if err != nil {
+		return nil, err
+	}
+
+	settings.Installation, err = GetInstallation(settings)
+	if err != nil {
+		return nil, err
+	}
+
+	userConfig, err := LoadUserConfig(settings)
+	if err != nil {
+		return nil, err
+	}
+
+	err = settings.SelectChannel(*channel, userConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	err = userConfig.SetMemory(settings, *minMemory, *maxMemory)
+	if err != nil {
+		return nil, err
+	}
+
+	err = userConfig.SetJava(settings, *javaSource)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(*platformFlag) > 0 {
+		platform, err := ParsePlatform(*platformFlag)
+		if err != nil {
+			return nil, err
+		}
+
+		fmt.Printf("Using the %v platform instead of the detected one.\n", platform)
+		settings.Platform = &platform
+	}
+
+	// The player's choice wins over the one of the launcher's author
+	if len(userConfig.PinnedVersion) > 0 {
+		settings.PinnedVersion = userConfig.PinnedVersion
+	}
+
+	return userConfig, nil
+}
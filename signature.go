@@ -0,0 +1,87 @@
+/**
+ * Spectrum-Bootstrap - A bootstrap for Minecraft launchers
+ * Copyright (C) 2023-2024 - Oxodao
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ **/
+
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Keys are ed25519 ones, encoded in base64:
+// - public keys are 32 bytes
+// - private keys are either the 32 bytes seed or the full 64 bytes key
+var (
+	ErrInvalidKey       = errors.New("invalid ed25519 key")
+	ErrInvalidSignature = errors.New("invalid signature")
+)
+
+func ParsePublicKeys(keys []string) ([]ed25519.PublicKey, error) {
+	parsed := []ed25519.PublicKey{}
+
+	for _, k := range keys {
+		data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(k))
+		if err != nil || len(data) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidKey, k)
+		}
+
+		parsed = append(parsed, ed25519.PublicKey(data))
+	}
+
+	return parsed, nil
+}
+
+func LoadPrivateKey(path string) (ed25519.PrivateKey, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(content)))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidKey, err)
+	}
+
+	switch len(data) {
+	case ed25519.SeedSize:
+		return ed25519.NewKeyFromSeed(data), nil
+	case ed25519.PrivateKeySize:
+		return ed25519.PrivateKey(data), nil
+	default:
+		return nil, fmt.Errorf("%w: unexpected length %v", ErrInvalidKey, len(data))
+	}
+}
+
+// The data is valid if any of the keys signed it
+func VerifySignature(keys []ed25519.PublicKey, data, signature []byte) error {
+	if len(signature) != ed25519.SignatureSize {
+		return ErrInvalidSignature
+	}
+
+	for _, k := range keys {
+		if ed25519.Verify(k, data, signature) {
+			return nil
+		}
+	}
+
+	return ErrInvalidSignature
+}
@@ -0,0 +1,65 @@
+/**
+ * Spectrum-Bootstrap - A bootstrap for Minecraft launchers
+ * Copyright (C) 2023-2024 - Oxodao
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ **/
+
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"testing"
+)
+
+func TestVerifySignature(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	otherKey, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := []byte(`{"version": "v1.0.0"}`)
+	signature := ed25519.Sign(privateKey, data)
+
+	tests := []struct {
+		name      string
+		keys      []ed25519.PublicKey
+		data      []byte
+		signature []byte
+		err       error
+	}{
+		{"signed by the key", []ed25519.PublicKey{publicKey}, data, signature, nil},
+		{"signed by one of the keys", []ed25519.PublicKey{otherKey, publicKey}, data, signature, nil},
+		{"signed by another key", []ed25519.PublicKey{otherKey}, data, signature, ErrInvalidSignature},
+		{"no keys", []ed25519.PublicKey{}, data, signature, ErrInvalidSignature},
+		{"modified document", []ed25519.PublicKey{publicKey}, []byte(`{"version": "v6.6.6"}`), signature, ErrInvalidSignature},
+		{"truncated signature", []ed25519.PublicKey{publicKey}, data, signature[:32], ErrInvalidSignature},
+		{"empty signature", []ed25519.PublicKey{publicKey}, data, nil, ErrInvalidSignature},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := VerifySignature(tt.keys, tt.data, tt.signature); !errors.Is(err, tt.err) {
+				t.Errorf("expected the error %v, got %v", tt.err, err)
+			}
+		})
+	}
+}
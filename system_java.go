@@ -0,0 +1,271 @@
+/**
+ * Spectrum-Bootstrap - A bootstrap for Minecraft launchers
+ * Copyright (C) 2023-2024 - Oxodao
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ **/
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+// Running a broken java must not block the bootstrap
+const JAVA_PROBE_TIMEOUT = 10 * time.Second
+
+// What the player chose with --java, any other value is the path of a java installation
+const (
+	JavaSourceDownload = "download"
+	JavaSourceSystem   = "system"
+)
+
+var (
+	ErrInvalidJava  = errors.New("invalid java installation")
+	ErrNoSystemJava = errors.New("no compatible java installation")
+)
+
+// A java installed on the computer, see ProbeJava
+type SystemJava struct {
+	Home    string
+	Version string
+	Arch    string
+}
+
+// The java architectures each go architecture can run
+var javaArches = map[string][]string{
+	"amd64": {"amd64", "x86_64"},
+	"arm64": {"aarch64", "arm64"},
+	"386":   {"x86", "i386", "i486", "i586", "i686"},
+	"arm":   {"arm", "aarch32", "armhf"},
+}
+
+func (r LauncherRuntime) AllowsSystemJava() bool {
+	return r.AllowSystem == nil || *r.AllowSystem
+}
+
+// Saves the java chosen on the command line
+func (c *UserConfig) SetJava(bs *BootstrapSettings, java string) error {
+	switch java {
+	case "":
+		return nil
+	case JavaSourceDownload:
+		c.Java = ""
+	case JavaSourceSystem:
+		c.Java = JavaSourceSystem
+	default:
+		path, err := filepath.Abs(java)
+		if err != nil {
+			return err
+		}
+
+		c.Java = path
+	}
+
+	return c.Save(bs)
+}
+
+// Runs the java to know what it is
+func ProbeJava(home string) (*SystemJava, error) {
+	executable := filepath.Join(home, "bin", "java")
+	if runtime.GOOS == "windows" {
+		executable += ".exe"
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), JAVA_PROBE_TIMEOUT)
+	defer cancel()
+
+	// The properties are written on stderr, i.e. "    java.version = 17.0.8"
+	out, err := exec.CommandContext(ctx, executable, "-XshowSettings:properties", "-version").CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v: %v", ErrInvalidJava, home, err)
+	}
+
+	return parseJavaProperties(home, out)
+}
+
+// Reads the output of java -XshowSettings:properties
+func parseJavaProperties(home string, out []byte) (*SystemJava, error) {
+	java := &SystemJava{Home: home}
+	for _, line := range strings.Split(string(out), "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), " = ")
+		if !ok {
+			continue
+		}
+
+		switch key {
+		case "java.version":
+			java.Version = strings.TrimSpace(value)
+		case "os.arch":
+			java.Arch = strings.TrimSpace(value)
+		}
+	}
+
+	if len(java.Version) == 0 {
+		return nil, fmt.Errorf("%w: %v: no java.version", ErrInvalidJava, home)
+	}
+
+	return java, nil
+}
+
+// Java 8 and older are named 1.8.0_392, the newer ones 17.0.8 or 17.0.8.1
+func (j *SystemJava) GetSemver() (*semver.Version, error) {
+	version := j.Version
+
+	if strings.HasPrefix(version, "1.") {
+		version = strings.Replace(strings.TrimPrefix(version, "1."), "_", ".", 1)
+	}
+
+	main, suffix, hasSuffix := strings.Cut(version, "-")
+	if parts := strings.Split(main, "."); len(parts) > 3 {
+		main = strings.Join(parts[:3], ".")
+	}
+
+	if hasSuffix {
+		main += "-" + suffix
+	}
+
+	return semver.NewVersion(main)
+}
+
+// A java made for another architecture might run emulated, we'd rather download the right one
+func (j *SystemJava) MatchesArch(platformArch string) bool {
+	for _, arch := range javaArches[platformArch] {
+		if strings.EqualFold(j.Arch, arch) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (j *SystemJava) GetJavaExecutable() string {
+	if runtime.GOOS == "windows" {
+		return filepath.Join(j.Home, "bin", "javaw.exe")
+	}
+
+	return filepath.Join(j.Home, "bin", "java")
+}
+
+// The folders where java is usually installed on this os
+func getJavaInstallDirs() []string {
+	patterns := []string{}
+
+	switch runtime.GOOS {
+	case "linux":
+		patterns = append(patterns, "/usr/lib/jvm/*", "/usr/lib64/jvm/*", "/opt/java/*")
+	case "darwin":
+		patterns = append(patterns, "/Library/Java/JavaVirtualMachines/*/Contents/Home")
+	case "windows":
+		for _, env := range []string{"ProgramFiles", "ProgramFiles(x86)"} {
+			if dir := os.Getenv(env); len(dir) > 0 {
+				for _, vendor := range []string{"Java", "Eclipse Adoptium", "Microsoft", "Zulu", "Amazon Corretto"} {
+					patterns = append(patterns, filepath.Join(dir, vendor, "*"))
+				}
+			}
+		}
+	}
+
+	dirs := []string{}
+	for _, p := range patterns {
+		matches, _ := filepath.Glob(p)
+		dirs = append(dirs, matches...)
+	}
+
+	return dirs
+}
+
+// The java installations to try, in order of preference
+// A path chosen by the player is the only one tried
+func GetJavaHomes(cfg *UserConfig) []string {
+	if cfg.Java != JavaSourceSystem {
+		// The path of the executable works too
+		if fi, err := os.Stat(cfg.Java); err == nil && !fi.IsDir() {
+			return []string{filepath.Dir(filepath.Dir(cfg.Java))}
+		}
+
+		return []string{cfg.Java}
+	}
+
+	homes := []string{}
+	if home := os.Getenv("JAVA_HOME"); len(home) > 0 {
+		homes = append(homes, home)
+	}
+
+	if executable, err := exec.LookPath("java"); err == nil {
+		// i.e. /usr/bin/java => /usr/lib/jvm/java-17-openjdk/bin/java
+		if resolved, err := filepath.EvalSymlinks(executable); err == nil {
+			executable = resolved
+		}
+
+		homes = append(homes, filepath.Dir(filepath.Dir(executable)))
+	}
+
+	homes = append(homes, getJavaInstallDirs()...)
+
+	seen := map[string]bool{}
+	unique := []string{}
+	for _, home := range homes {
+		home = filepath.Clean(home)
+		if !seen[home] {
+			seen[home] = true
+			unique = append(unique, home)
+		}
+	}
+
+	return unique
+}
+
+// Looks for a java of the player matching the version constraint of the runtime
+// Only version ranges can be checked, a runtime pinned to a build of the java manifest
+// or to its latest one is always downloaded
+func FindSystemJava(cfg *UserConfig, platform Platform, constraint string) (*SystemJava, error) {
+	c, err := semver.NewConstraint(constraint)
+	if len(constraint) == 0 || constraint == JAVA_VERSION_LATEST || err != nil {
+		return nil, fmt.Errorf("%w: the manifest gives no version range", ErrNoSystemJava)
+	}
+
+	for _, home := range GetJavaHomes(cfg) {
+		java, err := ProbeJava(home)
+		if err != nil {
+			fmt.Println("Skipping:", err)
+			continue
+		}
+
+		version, err := java.GetSemver()
+		if err != nil || !c.Check(version) {
+			fmt.Printf("Skipping java %v at %v, %v is required.\n", java.Version, home, constraint)
+			continue
+		}
+
+		if !java.MatchesArch(platform.Arch) {
+			fmt.Printf("Skipping java %v at %v, it is made for %v.\n", java.Version, home, java.Arch)
+			continue
+		}
+
+		return java, nil
+	}
+
+	return nil, ErrNoSystemJava
+}
@@ -0,0 +1,109 @@
+/**
+ * Spectrum-Bootstrap - A bootstrap for Minecraft launchers
+ * Copyright (C) 2023-2024 - Oxodao
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ **/
+
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseJavaProperties(t *testing.T) {
+	tests := []struct {
+		name    string
+		out     string
+		version string
+		arch    string
+		err     error
+	}{
+		{
+			"properties",
+			"Property settings:\n    java.home = /usr/lib/jvm/java-17\n    java.version = 17.0.8\n    os.arch = amd64\n\nopenjdk version \"17.0.8\"\n",
+			"17.0.8", "amd64", nil,
+		},
+		{"windows line endings", "    java.version = 1.8.0_392\r\n    os.arch = x86\r\n", "1.8.0_392", "x86", nil},
+		{"no version", "Error: could not create the Java Virtual Machine.\n", "", "", ErrInvalidJava},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			java, err := parseJavaProperties("/java", []byte(tt.out))
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("expected the error %v, got %v", tt.err, err)
+			} else if err != nil {
+				return
+			}
+
+			if java.Version != tt.version || java.Arch != tt.arch {
+				t.Errorf("expected %v %v, got %v %v", tt.version, tt.arch, java.Version, java.Arch)
+			}
+		})
+	}
+}
+
+func TestGetSemver(t *testing.T) {
+	tests := []struct {
+		version  string
+		expected string
+	}{
+		{"17.0.8", "17.0.8"},
+		{"17.0.8.1", "17.0.8"},
+		{"1.8.0_392", "8.0.392"},
+		{"21-ea", "21.0.0-ea"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			v, err := (&SystemJava{Version: tt.version}).GetSemver()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if v.String() != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, v)
+			}
+		})
+	}
+}
+
+func TestMatchesArch(t *testing.T) {
+	tests := []struct {
+		arch     string
+		goarch   string
+		expected bool
+	}{
+		{"amd64", "amd64", true},
+		{"x86_64", "amd64", true},
+		{"aarch64", "arm64", true},
+		{"i386", "386", true},
+		{"arm", "arm", true},
+		{"aarch32", "arm", true},
+		{"armhf", "arm", true},
+		{"aarch64", "arm", false},
+		{"amd64", "arm64", false},
+		{"x86", "amd64", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.arch+" on "+tt.goarch, func(t *testing.T) {
+			if matches := (&SystemJava{Arch: tt.arch}).MatchesArch(tt.goarch); matches != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, matches)
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+/**
+ * Spectrum-Bootstrap - A bootstrap for Minecraft launchers
+ * Copyright (C) 2023-2024 - Oxodao
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ **/
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	ErrUnknownVariable = errors.New("unknown variable")
+	ErrInvalidTemplate = errors.New("invalid template")
+)
+
+// Expands the placeholders of a manifest value:
+// - ${name} is replaced by the variable's value, an unknown variable is an error
+// - ${name:-default} uses the default when the variable is unknown or empty, the default can contain placeholders
+// - $$ is a literal $, a $ that is not followed by { is kept as is
+func ExpandTemplate(tpl string, variables map[string]string) (string, error) {
+	var sb strings.Builder
+
+	for i := 0; i < len(tpl); i++ {
+		if tpl[i] != '$' || i+1 >= len(tpl) {
+			sb.WriteByte(tpl[i])
+			continue
+		}
+
+		if tpl[i+1] == '$' {
+			sb.WriteByte('$')
+			i++
+			continue
+		}
+
+		if tpl[i+1] != '{' {
+			sb.WriteByte('$')
+			continue
+		}
+
+		end := findPlaceholderEnd(tpl, i+2)
+		if end < 0 {
+			return "", fmt.Errorf("%w: unterminated placeholder in %q", ErrInvalidTemplate, tpl)
+		}
+
+		value, err := expandPlaceholder(tpl[i+2:end], variables)
+		if err != nil {
+			return "", err
+		}
+
+		sb.WriteString(value)
+		i = end
+	}
+
+	return sb.String(), nil
+}
+
+// Finds the closing brace, taking care of the placeholders nested in defaults
+func findPlaceholderEnd(tpl string, start int) int {
+	depth := 0
+
+	for i := start; i < len(tpl); i++ {
+		switch {
+		case tpl[i] == '$' && i+1 < len(tpl) && tpl[i+1] == '$':
+			i++
+		case tpl[i] == '$' && i+1 < len(tpl) && tpl[i+1] == '{':
+			depth++
+			i++
+		case tpl[i] == '}':
+			if depth == 0 {
+				return i
+			}
+			depth--
+		}
+	}
+
+	return -1
+}
+
+func expandPlaceholder(placeholder string, variables map[string]string) (string, error) {
+	name, def, hasDefault := strings.Cut(placeholder, ":-")
+	if len(name) == 0 {
+		return "", fmt.Errorf("%w: empty placeholder", ErrInvalidTemplate)
+	}
+
+	value, ok := variables[name]
+	if ok && (len(value) > 0 || !hasDefault) {
+		return value, nil
+	}
+
+	if !hasDefault {
+		return "", fmt.Errorf("%w: %v", ErrUnknownVariable, name)
+	}
+
+	return ExpandTemplate(def, variables)
+}
+
+func ExpandTemplates(tpls []string, variables map[string]string) ([]string, error) {
+	values := []string{}
+
+	for _, tpl := range tpls {
+		value, err := ExpandTemplate(tpl, variables)
+		if err != nil {
+			return nil, err
+		}
+
+		values = append(values, value)
+	}
+
+	return values, nil
+}
@@ -0,0 +1,69 @@
+/**
+ * Spectrum-Bootstrap - A bootstrap for Minecraft launchers
+ * Copyright (C) 2023-2024 - Oxodao
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ **/
+
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestExpandTemplate(t *testing.T) {
+	variables := map[string]string{
+		"rootPath": "/games/launcher",
+		"profile":  "",
+		"os":       "linux",
+	}
+
+	tests := []struct {
+		name     string
+		template string
+		expected string
+		err      error
+	}{
+		{"plain", "--portable", "--portable", nil},
+		{"variable", "${rootPath}/launcher.jar", "/games/launcher/launcher.jar", nil},
+		{"several variables", "${os}-${rootPath}", "linux-/games/launcher", nil},
+		{"empty variable", "[${profile}]", "[]", nil},
+		{"default of an empty variable", "${profile:-default}", "default", nil},
+		{"default of an unknown variable", "${missing:-fallback}", "fallback", nil},
+		{"default not used", "${os:-windows}", "linux", nil},
+		{"nested default", "${missing:-${rootPath}/cache}", "/games/launcher/cache", nil},
+		{"empty default", "${missing:-}", "", nil},
+		{"literal dollar", "$${rootPath}", "${rootPath}", nil},
+		{"lone dollar", "cost: 5$", "cost: 5$", nil},
+		{"dollar without brace", "$HOME", "$HOME", nil},
+		{"unknown variable", "${missing}", "", ErrUnknownVariable},
+		{"unknown variable in default", "${missing:-${other}}", "", ErrUnknownVariable},
+		{"unterminated", "${rootPath", "", ErrInvalidTemplate},
+		{"empty placeholder", "${}", "", ErrInvalidTemplate},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value, err := ExpandTemplate(tt.template, variables)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("expected the error %v, got %v", tt.err, err)
+			}
+
+			if value != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, value)
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+/**
+ * Spectrum-Bootstrap - A bootstrap for Minecraft launchers
+ * Copyright (C) 2023-2024 - Oxodao
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ **/
+
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+)
+
+const USER_CONFIG_FILENAME = "bs_user.json"
+
+func GetUserConfigPath(bs *BootstrapSettings) string {
+	return filepath.Join(bs.LauncherPath, USER_CONFIG_FILENAME)
+}
+
+// Loads the preferences of the player
+// A missing file is not an error, it just means nothing was customized yet
+func LoadUserConfig(bs *BootstrapSettings) (*UserConfig, error) {
+	cfg := &UserConfig{}
+
+	data, err := os.ReadFile(GetUserConfigPath(bs))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	} else if err != nil {
+		return cfg, nil
+	}
+
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+func (c *UserConfig) Save(bs *BootstrapSettings) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(GetUserConfigPath(bs), data, 0644)
+}
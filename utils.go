@@ -21,17 +21,28 @@ package main
 import (
 	"crypto/sha1"
 	"crypto/sha256"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
 	"path/filepath"
+	"regexp"
 	"runtime"
+	"strings"
 )
 
 const NOT_DOWNLOADED = "NOT_DOWNLOADED"
 
+var (
+	ErrDownloadFailed = errors.New("download failed")
+	ErrHashMismatch   = errors.New("hash mismatch")
+)
+
+var sha256Regex = regexp.MustCompile(`^[0-9a-fA-F]{64}$`)
+
 func SetUserAgent(bs *BootstrapSettings, req *http.Request) {
 	req.Header.Set(
 		"User-Agent",
@@ -40,6 +51,16 @@ func SetUserAgent(bs *BootstrapSettings, req *http.Request) {
 }
 
 func GetOrCached[T interface{}](bs *BootstrapSettings, cachePath, url string) (*T, error) {
+	return getOrCached[T](bs, cachePath, url, false)
+}
+
+// Same as GetOrCached but when public keys are configured, the document must
+// have a valid signature at {url}.sig. Meant for the files the launcher's author controls.
+func GetOrCachedSigned[T interface{}](bs *BootstrapSettings, cachePath, url string) (*T, error) {
+	return getOrCached[T](bs, cachePath, url, true)
+}
+
+func getOrCached[T interface{}](bs *BootstrapSettings, cachePath, url string, signed bool) (*T, error) {
 	cached, cachedErr := LoadFromCache[T](cachePath)
 	// There is no error for file not found or file corrupted
 	// So if we have an error here, there is a deeper issue and we need to raise
@@ -47,60 +68,297 @@ func GetOrCached[T interface{}](bs *BootstrapSettings, cachePath, url string) (*
 		return nil, cachedErr
 	}
 
-	live, liveErr := DoGetRequest[T](bs, url)
+	requested := url
+	if signed {
+		url = bs.GetRelocatedUrl(url)
+	}
+
+	live, location, liveErr := doGetRequest[T](bs, url, signed)
 	// If we can't get it but the cache is loaded, no issue
 	// If we can't get it and no cache: CRASH
 	if liveErr != nil && cached != nil {
+		fmt.Printf("Failed to get %v, using the cached version: %v\n", url, liveErr)
 		return cached, nil
 	} else if liveErr != nil {
 		return nil, liveErr
 	}
 
+	// Only a signed document can move us somewhere else for good
+	if signed && len(bs.PublicKeys) > 0 && len(location) > 0 && location != url {
+		if err := bs.SaveRelocation(requested, location, live, cached); err != nil {
+			fmt.Printf("Failed to remember that %v moved to %v: %v\n", url, location, err)
+		} else {
+			fmt.Printf("%v moved permanently to %v, using it from now on.\n", url, location)
+		}
+	}
+
 	// We got it, lets cache it while we're at it!
-	err := os.MkdirAll(filepath.Dir(cachePath), os.ModePerm)
+	err := SaveToCache(cachePath, live)
+
+	return live, err
+}
+
+// For documents referenced by their sha256: the cached copy is used as long as
+// it matches, otherwise the document is downloaded and must match
+func GetOrCachedVerified[T interface{}](bs *BootstrapSettings, cachePath, url, hash string) (*T, error) {
+	if GetHash(cachePath) == hash {
+		cached, err := LoadFromCache[T](cachePath)
+		if err != nil || cached != nil {
+			return cached, err
+		}
+	}
+
+	data, err := FetchBytes(bs, url)
 	if err != nil {
 		return nil, err
 	}
 
+	if actual := fmt.Sprintf("%x", sha256.Sum256(data)); actual != hash {
+		return nil, fmt.Errorf("%w: %v has the hash %v instead of %v", ErrHashMismatch, url, actual, hash)
+	}
+
+	value := new(T)
+	if err := json.Unmarshal(data, value); err != nil {
+		return nil, fmt.Errorf("%v: %w", url, err)
+	}
+
+	// The raw document is kept so that its hash can be checked next time
+	if err := os.MkdirAll(filepath.Dir(cachePath), DIR_MODE); err != nil {
+		return nil, err
+	}
+
+	return value, os.WriteFile(cachePath, data, 0644)
+}
+
+func SaveToCache(cachePath string, value any) error {
+	err := os.MkdirAll(filepath.Dir(cachePath), DIR_MODE)
+	if err != nil {
+		return err
+	}
+
 	f, err := os.Create(cachePath)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	defer f.Close()
 
-	data, _ := json.MarshalIndent(live, "", "  ")
+	data, _ := json.MarshalIndent(value, "", "  ")
 	_, err = f.Write(data)
 
-	return live, err
+	return err
 }
 
-func DoGetRequest[T interface{}](bs *BootstrapSettings, url string) (*T, error) {
-	client := &http.Client{}
+// The url followed by its mirrors, if any
+func (bs *BootstrapSettings) GetMirroredUrls(url string) []string {
+	urls := []string{url}
 
-	req, err := http.NewRequest(
-		"GET",
-		url,
-		nil,
-	)
+	for prefix, mirrors := range bs.Mirrors {
+		if !strings.HasPrefix(url, prefix) {
+			continue
+		}
 
+		for _, m := range mirrors {
+			urls = append(urls, m+strings.TrimPrefix(url, prefix))
+		}
+	}
+
+	return urls
+}
+
+// Also returns where the url now lives when it was only reached through permanent redirects
+func openUrl(bs *BootstrapSettings, url string) (*http.Response, string, error) {
+	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
 	SetUserAgent(bs, req)
 
+	permanent := true
+	client := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 10 {
+				return errors.New("stopped after 10 redirects")
+			}
+
+			status := req.Response.StatusCode
+			if status != http.StatusMovedPermanently && status != http.StatusPermanentRedirect {
+				permanent = false
+			}
+
+			return nil
+		},
+	}
+
 	resp, err := client.Do(req)
 	if err != nil {
-		return nil, err
+		return nil, "", err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, "", fmt.Errorf("%w: %v returned %v", ErrDownloadFailed, url, resp.Status)
+	}
+
+	location := ""
+	if permanent && resp.Request.URL.String() != url {
+		location = resp.Request.URL.String()
+	}
+
+	return resp, location, nil
+}
+
+// Sends the request to the url then to its mirrors until one answers
+func OpenDownload(bs *BootstrapSettings, url string) (*http.Response, error) {
+	resp, _, err := openDownload(bs, url)
+
+	return resp, err
+}
+
+// Also returns where the url now lives when it was only reached through permanent redirects
+func openDownload(bs *BootstrapSettings, url string) (*http.Response, string, error) {
+	var lastErr error
+
+	for i, u := range bs.GetMirroredUrls(url) {
+		resp, location, err := openUrl(bs, u)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		// The mirrors are ours to configure, their redirects are not worth remembering
+		if i > 0 {
+			location = ""
+		}
+
+		return resp, location, nil
+	}
+
+	return nil, "", lastErr
+}
+
+// Downloads the file from its url then from its mirrors until one serves it with the expected hash
+// The mirrors are not trusted: a file that doesn't match is removed and the next one is tried
+func DownloadFile(bs *BootstrapSettings, f Downloadable) (int64, error) {
+	var lastErr error
+
+	for _, u := range bs.GetMirroredUrls(f.Url) {
+		n, err := downloadFileFrom(bs, u, f)
+		if err == nil {
+			return n, nil
+		}
+
+		os.Remove(f.Path)
+		lastErr = err
+	}
+
+	return 0, lastErr
+}
+
+func downloadFileFrom(bs *BootstrapSettings, url string, f Downloadable) (int64, error) {
+	resp, _, err := openUrl(bs, url)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	out, err := os.Create(f.Path)
+	if err != nil {
+		return 0, err
+	}
+	defer out.Close()
+
+	// Hashing while writing, to not read the file again
+	sha1Hash, sha256Hash := sha1.New(), sha256.New()
+	n, err := io.Copy(io.MultiWriter(out, sha1Hash, sha256Hash), resp.Body)
+	if err != nil {
+		return n, err
+	}
+
+	if len(f.Sha1) > 0 && !strings.EqualFold(fmt.Sprintf("%x", sha1Hash.Sum(nil)), f.Sha1) {
+		return n, fmt.Errorf("%w: %v", ErrHashMismatch, url)
+	}
+
+	if len(f.Sha256) > 0 && !strings.EqualFold(fmt.Sprintf("%x", sha256Hash.Sum(nil)), f.Sha256) {
+		return n, fmt.Errorf("%w: %v", ErrHashMismatch, url)
+	}
+
+	return n, out.Close()
+}
+
+func FetchBytes(bs *BootstrapSettings, url string) ([]byte, error) {
+	data, _, err := fetchBytes(bs, url)
+
+	return data, err
+}
+
+func fetchBytes(bs *BootstrapSettings, url string) ([]byte, string, error) {
+	resp, location, err := openDownload(bs, url)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+
+	return data, location, err
+}
+
+// Signatures are the base64 of the ed25519 signature of the raw document
+func VerifyDocument(bs *BootstrapSettings, url string, data []byte) error {
+	keys, err := ParsePublicKeys(bs.PublicKeys)
+	if err != nil {
+		return err
+	}
+
+	signature, err := FetchBytes(bs, url+".sig")
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(signature)))
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	}
+
+	if err := VerifySignature(keys, data, decoded); err != nil {
+		return fmt.Errorf("%w for %v", err, url)
+	}
+
+	return nil
+}
+
+func DoGetRequest[T interface{}](bs *BootstrapSettings, url string, signed bool) (*T, error) {
+	value, _, err := doGetRequest[T](bs, url, signed)
+
+	return value, err
+}
+
+// The signature is looked for next to where the document was found
+func doGetRequest[T interface{}](bs *BootstrapSettings, url string, signed bool) (*T, string, error) {
+	data, location, err := fetchBytes(bs, url)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if signed && len(bs.PublicKeys) > 0 {
+		signedUrl := url
+		if len(location) > 0 {
+			signedUrl = location
+		}
+
+		if err := VerifyDocument(bs, signedUrl, data); err != nil {
+			return nil, "", err
+		}
 	}
 
 	manifest := new(T)
-	err = json.NewDecoder(resp.Body).Decode(manifest)
+	err = json.Unmarshal(data, manifest)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
-	return manifest, nil
+	return manifest, location, nil
 }
 
 func LoadFromCache[T interface{}](filepath string) (*T, error) {
@@ -128,6 +386,17 @@ func LoadFromCache[T interface{}](filepath string) (*T, error) {
 	return manifest, nil
 }
 
+// The hashes also name the files of the caches, anything else could point outside of them
+func IsSha256(hash string) bool {
+	return sha256Regex.MatchString(hash)
+}
+
+// A short key for the caches of the documents downloaded from the url, so that
+// the document of another url is never used in place of this one
+func GetUrlCacheKey(url string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(url)))[:16]
+}
+
 func GetHash(filepath string) string {
 	f, err := os.Open(filepath)
 	if err != nil {
@@ -157,3 +426,26 @@ func GetHashSha1(filepath string) string {
 
 	return fmt.Sprintf("%x", h.Sum(nil))
 }
+
+func CopyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	err = os.MkdirAll(filepath.Dir(dst), DIR_MODE)
+	if err != nil {
+		return err
+	}
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	_, err = io.Copy(out, in)
+
+	return err
+}
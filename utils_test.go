@@ -0,0 +1,96 @@
+/**
+ * Spectrum-Bootstrap - A bootstrap for Minecraft launchers
+ * Copyright (C) 2023-2024 - Oxodao
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ **/
+
+package main
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDownloadFile(t *testing.T) {
+	content := []byte("the launcher")
+	sha1Hash := fmt.Sprintf("%x", sha1.Sum(content))
+	sha256Hash := fmt.Sprintf("%x", sha256.Sum256(content))
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/origin/", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "down", http.StatusServiceUnavailable)
+	})
+	mux.HandleFunc("/tampered/", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("something else"))
+	})
+	mux.HandleFunc("/mirror/", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(content)
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		mirrors []string
+		file    Downloadable
+		err     error
+	}{
+		{"sha256 from a mirror", []string{"/mirror/"}, Downloadable{Sha256: sha256Hash}, nil},
+		{"sha1 from a mirror", []string{"/mirror/"}, Downloadable{Sha1: sha1Hash}, nil},
+		{"uppercase hash", []string{"/mirror/"}, Downloadable{Sha256: strings.ToUpper(sha256Hash)}, nil},
+		{"tampered mirror skipped", []string{"/tampered/", "/mirror/"}, Downloadable{Sha256: sha256Hash}, nil},
+		{"only tampered mirrors", []string{"/tampered/"}, Downloadable{Sha256: sha256Hash}, ErrHashMismatch},
+		{"tampered sha1", []string{"/tampered/"}, Downloadable{Sha1: sha1Hash}, ErrHashMismatch},
+		{"no mirror", nil, Downloadable{Sha256: sha256Hash}, ErrDownloadFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mirrors := []string{}
+			for _, m := range tt.mirrors {
+				mirrors = append(mirrors, server.URL+m)
+			}
+
+			bs := &BootstrapSettings{Mirrors: map[string][]string{server.URL + "/origin/": mirrors}}
+
+			f := tt.file
+			f.Url = server.URL + "/origin/launcher.jar"
+			f.Path = filepath.Join(t.TempDir(), "launcher.jar")
+
+			_, err := DownloadFile(bs, f)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("expected the error %v, got %v", tt.err, err)
+			}
+
+			data, readErr := os.ReadFile(f.Path)
+			if tt.err != nil {
+				if !os.IsNotExist(readErr) {
+					t.Errorf("expected the file to be removed, got %q", data)
+				}
+			} else if string(data) != string(content) {
+				t.Errorf("expected %q, got %q", content, data)
+			}
+		})
+	}
+}